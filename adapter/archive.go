@@ -0,0 +1,30 @@
+package adapter
+
+import (
+	"containerup/adapter/v3adapter"
+	"context"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"io"
+)
+
+func ContainerStat(ctx context.Context, nameOrID string, path string) (*entities.ContainerStatReport, error) {
+	if legacy {
+		return v3adapter.ContainerStat(ctx, nameOrID, path)
+	}
+	return containers.Stat(ctx, nameOrID, path)
+}
+
+func ContainerCopyFromArchive(ctx context.Context, nameOrID string, path string, reader io.Reader, options *containers.CopyOptions) (entities.ContainerCopyFunc, error) {
+	if legacy {
+		return v3adapter.ContainerCopyFromArchive(ctx, nameOrID, path, reader, options)
+	}
+	return containers.CopyFromArchiveWithOptions(ctx, nameOrID, path, reader, options)
+}
+
+func ContainerCopyToArchive(ctx context.Context, nameOrID string, path string, writer io.Writer) (entities.ContainerCopyFunc, error) {
+	if legacy {
+		return v3adapter.ContainerCopyToArchive(ctx, nameOrID, path, writer)
+	}
+	return containers.CopyToArchive(ctx, nameOrID, path, writer)
+}
@@ -0,0 +1,24 @@
+package adapter
+
+import (
+	"containerup/adapter/v3adapter"
+	"context"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"io"
+)
+
+// ContainerAttach attaches to the main process of a running container until it exits, or the detach keys are read from stdin.
+// It returns define.ErrDetach if detached.
+func ContainerAttach(ctx context.Context, nameOrID string, stdin io.Reader, stdout, stderr io.Writer, options *containers.AttachOptions) error {
+	if legacy {
+		return v3adapter.ContainerAttach(ctx, nameOrID, stdin, stdout, stderr, options)
+	}
+	return containers.Attach(ctx, nameOrID, stdin, stdout, stderr, nil, options)
+}
+
+func ContainerResizeTTY(ctx context.Context, nameOrID string, options *containers.ResizeTTYOptions) error {
+	if legacy {
+		return v3adapter.ContainerResizeTTY(ctx, nameOrID, options)
+	}
+	return containers.ResizeContainerTTY(ctx, nameOrID, options)
+}
@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"containerup/adapter/v3adapter"
+	"context"
+	"github.com/containers/podman/v4/pkg/bindings"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"io"
+	"net/http"
+)
+
+// ContainerCheckpoint checkpoints the container. If export is not nil, the checkpoint archive is streamed into it,
+// instead of being written to a local file as the bindings do.
+func ContainerCheckpoint(ctx context.Context, nameOrID string, options *containers.CheckpointOptions, export io.Writer) (*entities.CheckpointReport, error) {
+	if legacy {
+		return v3adapter.ContainerCheckpoint(ctx, nameOrID, options, export)
+	}
+	if export == nil {
+		return containers.Checkpoint(ctx, nameOrID, options)
+	}
+
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	params.Set("export", "true")
+
+	response, err := conn.DoRequest(ctx, nil, http.MethodPost, "/containers/%s/checkpoint", params, nil, nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, response.Process(nil)
+	}
+	_, err = io.Copy(export, response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.CheckpointReport{Id: nameOrID}, nil
+}
+
+// ContainerRestore restores the container. If archive is not nil, the container is restored from it,
+// instead of from a local file as the bindings do.
+func ContainerRestore(ctx context.Context, nameOrID string, options *containers.RestoreOptions, archive io.Reader) (*entities.RestoreReport, error) {
+	if legacy {
+		return v3adapter.ContainerRestore(ctx, nameOrID, options, archive)
+	}
+	if archive == nil {
+		return containers.Restore(ctx, nameOrID, options)
+	}
+
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	params.Set("import", "true")
+
+	// the name is ignored by Podman when importing
+	response, err := conn.DoRequest(ctx, archive, http.MethodPost, "/containers/%s/restore", params, nil, "import")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var report entities.RestoreReport
+	return &report, response.Process(&report)
+}
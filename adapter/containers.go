@@ -3,12 +3,15 @@ package adapter
 import (
 	"containerup/adapter/v3adapter"
 	"context"
+	"github.com/blang/semver/v4"
 	"github.com/containers/podman/v4/libpod/define"
 	"github.com/containers/podman/v4/pkg/api/handlers"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/containers/podman/v4/pkg/domain/entities"
 	"github.com/containers/podman/v4/pkg/domain/entities/reports"
 	"github.com/containers/podman/v4/pkg/specgen"
+	"github.com/containers/storage/pkg/archive"
+	"io"
 )
 
 func ContainerCreateWithSpec(ctx context.Context, s *specgen.SpecGenerator, options *containers.CreateOptions) (entities.ContainerCreateResponse, error) {
@@ -124,3 +127,32 @@ func ContainerResizeExecTTY(ctx context.Context, sessionId string, options *cont
 	}
 	return containers.ResizeExecTTY(ctx, sessionId, options)
 }
+
+// ContainerUpdate changes the resource limits of a container in place, which requires Podman 4.3 or later.
+func ContainerUpdate(ctx context.Context, options *entities.ContainerUpdateOptions) (string, error) {
+	if legacy || ServiceVersion(ctx).LT(semver.Version{Major: 4, Minor: 3}) {
+		return "", ErrUnsupported
+	}
+	return containers.Update(ctx, options)
+}
+
+func ContainerDiff(ctx context.Context, nameOrID string, options *containers.DiffOptions) ([]archive.Change, error) {
+	if legacy {
+		return v3adapter.ContainerDiff(ctx, nameOrID, options)
+	}
+	return containers.Diff(ctx, nameOrID, options)
+}
+
+func ContainerTop(ctx context.Context, nameOrID string, options *containers.TopOptions) ([]string, error) {
+	if legacy {
+		return v3adapter.ContainerTop(ctx, nameOrID, options)
+	}
+	return containers.Top(ctx, nameOrID, options)
+}
+
+func ContainerExport(ctx context.Context, nameOrID string, w io.Writer, options *containers.ExportOptions) error {
+	if legacy {
+		return v3adapter.ContainerExport(ctx, nameOrID, w, options)
+	}
+	return containers.Export(ctx, nameOrID, w, options)
+}
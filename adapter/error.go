@@ -0,0 +1,7 @@
+package adapter
+
+import "errors"
+
+var (
+	ErrUnsupported = errors.New("not supported by the version of Podman")
+)
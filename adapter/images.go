@@ -5,6 +5,7 @@ import (
 	"context"
 	"github.com/containers/podman/v4/pkg/bindings/images"
 	"github.com/containers/podman/v4/pkg/domain/entities"
+	"io"
 )
 
 func ImageList(ctx context.Context, options *images.ListOptions) ([]*entities.ImageSummary, error) {
@@ -41,3 +42,24 @@ func ImageGet(ctx context.Context, nameOrID string, options *images.GetOptions)
 	}
 	return images.GetImage(ctx, nameOrID, options)
 }
+
+func ImageImport(ctx context.Context, r io.Reader, options *images.ImportOptions) (*entities.ImageImportReport, error) {
+	if legacy {
+		return v3adapter.ImageImport(ctx, r, options)
+	}
+	return images.Import(ctx, r, options)
+}
+
+func ImageExport(ctx context.Context, nameOrIDs []string, w io.Writer, options *images.ExportOptions) error {
+	if legacy {
+		return v3adapter.ImageExport(ctx, nameOrIDs, w, options)
+	}
+	return images.Export(ctx, nameOrIDs, w, options)
+}
+
+func ImageLoad(ctx context.Context, r io.Reader) (*entities.ImageLoadReport, error) {
+	if legacy {
+		return v3adapter.ImageLoad(ctx, r)
+	}
+	return images.Load(ctx, r)
+}
@@ -0,0 +1,99 @@
+package v3adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/copy"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+func ContainerStat(ctx context.Context, nameOrID string, path string) (*entities.ContainerStatReport, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("path", path)
+	ep := fmt.Sprintf("/containers/%s/archive", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodHead, ep, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var finalErr error
+	if resp.StatusCode == http.StatusNotFound {
+		finalErr = copy.ErrENOENT
+	} else if resp.StatusCode != http.StatusOK {
+		finalErr = errors.New(resp.Status)
+	}
+
+	var statReport *entities.ContainerStatReport
+
+	fileInfo, err := copy.ExtractFileInfoFromHeader(&resp.Header)
+	if err != nil && finalErr == nil {
+		return nil, err
+	}
+
+	if fileInfo != nil {
+		statReport = &entities.ContainerStatReport{FileInfo: *fileInfo}
+	}
+
+	return statReport, finalErr
+}
+
+func ContainerCopyFromArchive(ctx context.Context, nameOrID string, path string, reader io.Reader, options *containers.CopyOptions) (entities.ContainerCopyFunc, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	params.Set("path", path)
+	ep := fmt.Sprintf("/containers/%s/archive", nameOrID)
+
+	return func() error {
+		resp, err := conn.DoRequest(ctx, reader, http.MethodPut, ep, params)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		return checkResp(resp)
+	}, nil
+}
+
+func ContainerCopyToArchive(ctx context.Context, nameOrID string, path string, writer io.Writer) (entities.ContainerCopyFunc, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("path", path)
+	ep := fmt.Sprintf("/containers/%s/archive", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodGet, ep, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkResp(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer resp.Body.Close()
+		_, err := io.Copy(writer, resp.Body)
+		return err
+	}, nil
+}
@@ -0,0 +1,200 @@
+package v3adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/moby/term"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ContainerAttach attaches to a running container.
+// This method and related methods are heavily copied and modified from
+// https://github.com/containers/podman/blob/v3.0.1/pkg/bindings/containers/attach.go
+func ContainerAttach(ctx context.Context, nameOrID string, stdin io.Reader, stdout, stderr io.Writer, options *containers.AttachOptions) error {
+	if options == nil {
+		options = new(containers.AttachOptions)
+	}
+	conn, err := getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctnr, err := ContainerInspect(ctx, nameOrID, nil)
+	if err != nil {
+		return err
+	}
+	isTerm := ctnr.Config != nil && ctnr.Config.Tty
+
+	params, err := options.ToParams()
+	if err != nil {
+		return err
+	}
+	detachKeys := []byte{}
+	if options.Changed("DetachKeys") {
+		detachKeys, err = term.ToBytes(options.GetDetachKeys())
+		if err != nil {
+			return fmt.Errorf("invalid detach keys: %w", err)
+		}
+	}
+	if stdin != nil {
+		params.Set("stdin", "true")
+	}
+	if stdout != nil {
+		params.Set("stdout", "true")
+	}
+	if stderr != nil {
+		params.Set("stderr", "true")
+	}
+
+	var socket net.Conn
+	socketSet := false
+	dialContext := conn.Client.Transport.(*http.Transport).DialContext
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			c, err := dialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			if !socketSet {
+				socket = c
+				socketSet = true
+			}
+			return c, err
+		},
+		IdleConnTimeout: time.Duration(0),
+	}
+	conn.Client.Transport = t
+
+	ep := fmt.Sprintf("/containers/%s/attach", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodPost, ep, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return err
+	}
+
+	stdinChan := make(chan error, 1)
+	if stdin != nil {
+		go func() {
+			_, err := CopyDetachable(socket, stdin, detachKeys)
+			if err != nil && !errors.Is(err, ErrDetach) {
+				log.Printf("failed to write input to service: %v", err)
+			}
+			stdinChan <- err
+		}()
+	}
+
+	if isTerm {
+		if stdout == nil {
+			return fmt.Errorf("container %q requires stdout to be set", ctnr.ID)
+		}
+		stdoutChan := make(chan error, 1)
+		go func() {
+			// If not multiplex'ed, read from server and write to stdout
+			_, err := io.Copy(stdout, socket)
+			stdoutChan <- err
+		}()
+
+		for {
+			select {
+			case err := <-stdoutChan:
+				return err
+			case err := <-stdinChan:
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	buffer := make([]byte, 1024)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			// Read multiplexed channels and write to appropriate stream
+			fd, l, err := containers.DemuxHeader(socket, buffer)
+			if err != nil {
+				if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+			frame, err := containers.DemuxFrame(socket, buffer, l)
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			switch fd {
+			case 0, 1:
+				if stdout != nil {
+					_, err = stdout.Write(frame[0:l])
+				}
+			case 2:
+				if stderr != nil {
+					_, err = stderr.Write(frame[0:l])
+				}
+			case 3:
+				err = fmt.Errorf("error from service from stream: %s", frame)
+			default:
+				err = fmt.Errorf("unrecognized channel '%d' in header, 0-3 supported", fd)
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case err := <-readErr:
+			return err
+		case err := <-stdinChan:
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ContainerResizeTTY sets the size of the TTY of the container.
+func ContainerResizeTTY(ctx context.Context, nameOrID string, options *containers.ResizeTTYOptions) error {
+	if options == nil {
+		options = new(containers.ResizeTTYOptions)
+	}
+	conn, err := getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if options.Height != nil {
+		params.Set("h", strconv.Itoa(*options.Height))
+	}
+	if options.Width != nil {
+		params.Set("w", strconv.Itoa(*options.Width))
+	}
+	params.Set("running", "true")
+
+	ep := fmt.Sprintf("/containers/%s/resize", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodPost, ep, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResp(resp)
+}
@@ -0,0 +1,85 @@
+package v3adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"io"
+	"net/http"
+)
+
+func ContainerCheckpoint(ctx context.Context, nameOrID string, options *containers.CheckpointOptions, export io.Writer) (*entities.CheckpointReport, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	if export != nil {
+		params.Set("export", "true")
+	}
+	ep := fmt.Sprintf("/containers/%s/checkpoint", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodPost, ep, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return nil, err
+	}
+
+	if export != nil {
+		_, err = io.Copy(export, resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &entities.CheckpointReport{Id: nameOrID}, nil
+	}
+
+	var report entities.CheckpointReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func ContainerRestore(ctx context.Context, nameOrID string, options *containers.RestoreOptions, archive io.Reader) (*entities.RestoreReport, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	if archive != nil {
+		params.Set("import", "true")
+		// the name is ignored by Podman when importing
+		nameOrID = "import"
+	}
+	ep := fmt.Sprintf("/containers/%s/restore", nameOrID)
+	resp, err := conn.DoRequest(ctx, archive, http.MethodPost, ep, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return nil, err
+	}
+
+	var report entities.RestoreReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -7,12 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/containers/podman/v4/libpod/define"
+	"github.com/containers/podman/v4/pkg/api/handlers"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/containers/podman/v4/pkg/domain/entities"
 	"github.com/containers/podman/v4/pkg/domain/entities/reports"
 	"github.com/containers/podman/v4/pkg/specgen"
+	"github.com/containers/storage/pkg/archive"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 func ContainerCreateWithSpec(ctx context.Context, s *specgen.SpecGenerator, options *containers.CreateOptions) (entities.ContainerCreateResponse, error) {
@@ -281,12 +285,31 @@ func ContainerInspect(ctx context.Context, nameOrID string, options *containers.
 		return nil, err
 	}
 
+	d, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
 	var result define.InspectContainerData
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	err = json.Unmarshal(d, &result)
 	if err != nil {
 		return nil, err
 	}
 
+	// the health check results are named `Healthcheck` in v3, instead of `Health`
+	var legacyResult struct {
+		State *struct {
+			Healthcheck define.HealthCheckResults `json:"Healthcheck"`
+		}
+	}
+	err = json.Unmarshal(d, &legacyResult)
+	if err != nil {
+		return nil, err
+	}
+	if result.State != nil && legacyResult.State != nil && result.State.Health.Status == "" {
+		result.State.Health = legacyResult.State.Healthcheck
+	}
+
 	return &result, nil
 }
 
@@ -407,3 +430,88 @@ func ContainerStats(ctx context.Context, ctns []string, options *containers.Stat
 
 	return statsChan, nil
 }
+
+func ContainerDiff(ctx context.Context, nameOrID string, options *containers.DiffOptions) ([]archive.Change, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	ep := fmt.Sprintf("/containers/%s/changes", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodGet, ep, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return nil, err
+	}
+
+	var changes []archive.Change
+	err = json.NewDecoder(resp.Body).Decode(&changes)
+	return changes, err
+}
+
+func ContainerTop(ctx context.Context, nameOrID string, options *containers.TopOptions) ([]string, error) {
+	if options == nil {
+		options = new(containers.TopOptions)
+	}
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	if options.Changed("Descriptors") {
+		params.Add("ps_args", strings.Join(options.GetDescriptors(), ","))
+	}
+	ep := fmt.Sprintf("/containers/%s/top", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodGet, ep, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return nil, err
+	}
+
+	var body handlers.ContainerTopOKBody
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	// same as the bindings of v4, cells are joined by tabs
+	topOutput := []string{strings.Join(body.Titles, "\t")}
+	for _, out := range body.Processes {
+		topOutput = append(topOutput, strings.Join(out, "\t"))
+	}
+	return topOutput, nil
+}
+
+func ContainerExport(ctx context.Context, nameOrID string, w io.Writer, _ *containers.ExportOptions) error {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	ep := fmt.Sprintf("/containers/%s/export", nameOrID)
+	resp, err := conn.DoRequest(ctx, nil, http.MethodGet, ep, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
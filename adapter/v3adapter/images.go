@@ -8,6 +8,7 @@ import (
 	"github.com/containers/podman/v4/pkg/bindings/images"
 	"github.com/containers/podman/v4/pkg/domain/entities"
 	"github.com/containers/podman/v4/pkg/errorhandling"
+	"io"
 	"net/http"
 	"net/url"
 )
@@ -127,3 +128,82 @@ func ImageGet(ctx context.Context, nameOrID string, options *images.GetOptions)
 
 	return &result, nil
 }
+
+func ImageImport(ctx context.Context, r io.Reader, options *images.ImportOptions) (*entities.ImageImportReport, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.DoRequest(ctx, r, http.MethodPost, "/images/import", params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return nil, err
+	}
+
+	var report entities.ImageImportReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func ImageExport(ctx context.Context, nameOrIDs []string, w io.Writer, options *images.ExportOptions) error {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	params, err := options.ToParams()
+	if err != nil {
+		return err
+	}
+	for _, ref := range nameOrIDs {
+		params.Add("references", ref)
+	}
+	resp, err := conn.DoRequest(ctx, nil, http.MethodGet, "/images/export", params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func ImageLoad(ctx context.Context, r io.Reader) (*entities.ImageLoadReport, error) {
+	conn, err := getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.DoRequest(ctx, r, http.MethodPost, "/images/load", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResp(resp); err != nil {
+		return nil, err
+	}
+
+	var report entities.ImageLoadReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
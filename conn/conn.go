@@ -7,9 +7,11 @@ import (
 )
 
 type ctxKeyT struct{}
+type ctxKeyDetachedT struct{}
 
 var (
-	ctxKey = &ctxKeyT{}
+	ctxKey         = &ctxKeyT{}
+	ctxKeyDetached = &ctxKeyDetachedT{}
 )
 
 func ConnectionChainer(uri string) (func(http.HandlerFunc) http.HandlerFunc, error) {
@@ -26,6 +28,8 @@ func ConnectionChainer(uri string) (func(http.HandlerFunc) http.HandlerFunc, err
 				defer connCancel()
 				<-reqCtx.Done()
 			}()
+			reqCtx = context.WithValue(reqCtx, ctxKeyDetached, conn)
+			connCtx = context.WithValue(connCtx, ctxKeyDetached, conn)
 			next(w, req.WithContext(context.WithValue(reqCtx, ctxKey, connCtx)))
 		}
 	}, nil
@@ -37,3 +41,12 @@ func GetConn(ctx context.Context) context.Context {
 	}
 	return nil
 }
+
+// GetDetachedConn returns the connection not cancelled with the request, from the request or its connection,
+// for the operations living longer than the request.
+func GetDetachedConn(ctx context.Context) context.Context {
+	if c := ctx.Value(ctxKeyDetached); c != nil {
+		return c.(context.Context)
+	}
+	return nil
+}
@@ -19,8 +19,11 @@ var (
 )
 
 type action struct {
-	Action  string `json:"action"`
-	RepoTag string `json:"repoTag"`
+	Action     string         `json:"action"`
+	RepoTag    string         `json:"repoTag"`
+	Clone      *cloneReq      `json:"clone"`
+	Checkpoint *checkpointReq `json:"checkpoint"`
+	Restore    *restoreReq    `json:"restore"`
 }
 
 func Action(w http.ResponseWriter, req *http.Request) {
@@ -37,6 +40,7 @@ func Action(w http.ResponseWriter, req *http.Request) {
 
 	pmConn := conn.GetConn(req.Context())
 
+	ret := any(true)
 	switch act.Action {
 	case "stop":
 		err = stop(pmConn, nameOrId)
@@ -46,6 +50,17 @@ func Action(w http.ResponseWriter, req *http.Request) {
 		err = remove(pmConn, nameOrId)
 	case "commit":
 		err = commit(pmConn, nameOrId, act.RepoTag)
+	case "clone":
+		var c *createReq
+		c, err = cloneCreateReq(pmConn, nameOrId, act.Clone)
+		if err == nil {
+			createAndReturn(w, pmConn, c, nil)
+			return
+		}
+	case "checkpoint":
+		ret, err = checkpoint(pmConn, nameOrId, act.Checkpoint)
+	case "restore":
+		ret, err = restore(pmConn, nameOrId, act.Restore)
 	default:
 		http.Error(w, "unrecognized action", http.StatusBadRequest)
 		return
@@ -64,7 +79,7 @@ func Action(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	utils.Return(w, true)
+	utils.Return(w, ret)
 }
 
 func stop(ctx context.Context, nameOrID string) error {
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"bufio"
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/login"
+	"containerup/recording"
+	"containerup/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/gorilla/mux"
+	"github.com/moby/term"
+	"io"
+	"log"
+	"net/http"
+)
+
+const (
+	defaultDetachKeys = "ctrl-p,ctrl-q"
+)
+
+// Attach attaches to the stdio of the main process of a running container, with the framing of Exec.
+// Stdin is attached with interactive=1, and the client detaches by sending detachKeys (ctrl-p,ctrl-q by default).
+func Attach(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+
+	interactive := query.Get("interactive") == "1" || query.Get("stdin") == "true"
+	detachKeys := query.Get("detachKeys")
+	if detachKeys == "" {
+		detachKeys = defaultDetachKeys
+	}
+	if _, err := term.ToBytes(detachKeys); err != nil {
+		http.Error(w, fmt.Sprintf("invalid detach keys: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if data.State == nil || !data.State.Running {
+		http.Error(w, fmt.Sprintf("Container %s is not running", nameOrId), http.StatusConflict)
+		return
+	}
+
+	ws, proto := upgradeExec(w, req)
+	if ws == nil {
+		return
+	}
+
+	var rec *recording.Recorder
+	if recording.Enabled(req) {
+		rec, err = recording.Start(recording.Tags{
+			User:      login.Username(),
+			Container: nameOrId,
+			Remote:    req.RemoteAddr,
+		}, []string{"attach"})
+		if err != nil {
+			proto.fail(ws, fmt.Errorf("cannot record the session: %v", err))
+			return
+		}
+	}
+
+	stdOutReader, stdOutWriter := io.Pipe()
+	stdErrReader, stdErrWriter := io.Pipe()
+	var stdInWriter *io.PipeWriter
+	// nil unless interactive, as the bindings check the interface against nil
+	var stdIn io.Reader
+	if interactive {
+		var stdInReader *io.PipeReader
+		stdInReader, stdInWriter = io.Pipe()
+		stdIn = bufio.NewReader(stdInReader)
+	}
+
+	var stdInCloser io.WriteCloser
+	if stdInWriter != nil {
+		stdInCloser = stdInWriter
+	}
+	pmConn, stopByServer, waitEnd := execTransmitter(pmConn, ws, proto, containerResizer(data.ID), stdOutReader, stdErrReader, stdInCloser, rec)
+
+	opts := (&containers.AttachOptions{}).WithDetachKeys(detachKeys)
+	err = adapter.ContainerAttach(pmConn, data.ID, stdIn, stdOutWriter, stdErrWriter, opts)
+	if errors.Is(err, define.ErrDetach) {
+		err = nil
+	}
+
+	exitCode := -1
+	// websocket can be closed by user
+	if pmConn.Err() == nil {
+		inspectOut, err := adapter.ContainerInspect(pmConn, data.ID, nil)
+		if err != nil {
+			log.Printf("inspect err : %v", err)
+		} else if inspectOut.State != nil && !inspectOut.State.Running {
+			exitCode = int(inspectOut.State.ExitCode)
+		}
+	}
+
+	stopByServer(err, exitCode)
+	rec.Close(exitCode)
+
+	waitEnd()
+}
+
+// containerResizer resizes the TTY of the container.
+func containerResizer(nameOrId string) func(context.Context, int, int) error {
+	return func(ctx context.Context, w, h int) error {
+		return adapter.ContainerResizeTTY(ctx, nameOrId, &containers.ResizeTTYOptions{
+			Height: &h,
+			Width:  &w,
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/utils"
+	"context"
+	"fmt"
+	"github.com/containers/storage/pkg/archive"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	maxChangesSized    = 1000
+	changesSizeWorkers = 8
+)
+
+type changeEntry struct {
+	Path string `json:"path"`
+	Size *int64 `json:"size,omitempty"`
+}
+
+type changesResp struct {
+	Added   []*changeEntry `json:"added"`
+	Changed []*changeEntry `json:"changed"`
+	Deleted []*changeEntry `json:"deleted"`
+	// sizes are only filled for the first entries, as each of them costs a request
+	SizeTruncated bool `json:"sizeTruncated"`
+}
+
+func Changes(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	changes, err := adapter.ContainerDiff(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	ret := &changesResp{
+		Added:   []*changeEntry{},
+		Changed: []*changeEntry{},
+		Deleted: []*changeEntry{},
+	}
+	var sized []*changeEntry
+	for _, c := range changes {
+		entry := &changeEntry{Path: c.Path}
+		switch c.Kind {
+		case archive.ChangeAdd:
+			ret.Added = append(ret.Added, entry)
+			sized = append(sized, entry)
+		case archive.ChangeModify:
+			ret.Changed = append(ret.Changed, entry)
+			sized = append(sized, entry)
+		case archive.ChangeDelete:
+			ret.Deleted = append(ret.Deleted, entry)
+		}
+	}
+
+	if req.URL.Query().Get("size") == "1" {
+		if len(sized) > maxChangesSized {
+			sized = sized[:maxChangesSized]
+			ret.SizeTruncated = true
+		}
+		fillChangeSizes(pmConn, nameOrId, sized)
+	}
+
+	utils.Return(w, ret)
+}
+
+// fillChangeSizes stats the changed paths concurrently. Paths failed to stat are left without size.
+func fillChangeSizes(ctx context.Context, nameOrId string, entries []*changeEntry) {
+	ch := make(chan *changeEntry)
+	var wg sync.WaitGroup
+	for i := 0; i < changesSizeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range ch {
+				stat, err := adapter.ContainerStat(ctx, nameOrId, entry.Path)
+				if err != nil || stat == nil {
+					continue
+				}
+				size := stat.Size
+				entry.Size = &size
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		ch <- entry
+	}
+	close(ch)
+	wg.Wait()
+}
@@ -0,0 +1,245 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/transfer"
+	"containerup/utils"
+	"context"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	checkpointAvailable   = "available"
+	checkpointUnavailable = "unavailable"
+	checkpointUnknown     = "unknown"
+)
+
+var (
+	// a failure caused by CRIU is remembered, as it cannot be detected remotely in advance
+	criuFailure      string
+	criuFailureMutex sync.Mutex
+
+	// errors showing CRIU is missing or broken on the host, in lower case,
+	// rather than failing for the container checkpointed
+	criuHostFailures = []string{
+		"requires at least criu",
+		"check for criu",
+		"without criu",
+		"criu: executable file not found",
+		"\"criu\": executable file not found",
+		"libcriu",
+		"criu is not installed",
+	}
+)
+
+type checkpointReq struct {
+	Keep           bool `json:"keep"`
+	LeaveRunning   bool `json:"leaveRunning"`
+	TcpEstablished bool `json:"tcpEstablished"`
+}
+
+type restoreReq struct {
+	Keep            bool `json:"keep"`
+	TcpEstablished  bool `json:"tcpEstablished"`
+	IgnoreStaticIp  bool `json:"ignoreStaticIp"`
+	IgnoreStaticMac bool `json:"ignoreStaticMac"`
+}
+
+type checkpointSupportResp struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func CheckpointSupport(w http.ResponseWriter, req *http.Request) {
+	pmConn := conn.GetConn(req.Context())
+
+	ret, err := checkpointSupport(pmConn)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Return(w, ret)
+}
+
+// CheckpointExport checkpoints the container and responds with the archive. It is stopped unless leaveRunning=1.
+func CheckpointExport(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+
+	data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	opts := checkpointOptions(&checkpointReq{
+		Keep:           query.Get("keep") == "1",
+		LeaveRunning:   query.Get("leaveRunning") == "1",
+		TcpEstablished: query.Get("tcpEstablished") == "1",
+	})
+
+	t := transfer.Start(req, "checkpoint-export", data.Name, -1)
+	w.Header().Set("X-Transfer-Id", t.Id())
+	dw := transfer.NewDownloadWriter(w, "application/octet-stream", data.Name+"-checkpoint.tar")
+
+	_, err = adapter.ContainerCheckpoint(pmConn, data.ID, opts, t.Writer(dw))
+	t.Finish(err)
+	checkCriuFailure(err)
+	if err != nil {
+		if !dw.Started() {
+			http.Error(w, fmt.Sprintf("Cannot checkpoint container %s: %v", nameOrId, err), http.StatusInternalServerError)
+			return
+		}
+		utils.AbortResponse("Checkpoint container %s: %v", nameOrId, err)
+	}
+}
+
+func RestoreImport(w http.ResponseWriter, req *http.Request) {
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+	defer req.Body.Close()
+
+	opts := restoreOptions(&restoreReq{
+		Keep:            query.Get("keep") == "1",
+		TcpEstablished:  query.Get("tcpEstablished") == "1",
+		IgnoreStaticIp:  query.Get("ignoreStaticIp") == "1",
+		IgnoreStaticMac: query.Get("ignoreStaticMac") == "1",
+	})
+	if name := query.Get("name"); name != "" {
+		// required if the original container still exists
+		opts.WithName(name)
+	}
+
+	t := transfer.Start(req, "checkpoint-import", query.Get("name"), req.ContentLength)
+	w.Header().Set("X-Transfer-Id", t.Id())
+
+	report, err := adapter.ContainerRestore(pmConn, "", opts, t.Reader(req.Body))
+	t.Finish(err)
+	checkCriuFailure(err)
+	if err != nil {
+		if utils.IsErr409(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Cannot restore container: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, report)
+}
+
+// checkpoint checkpoints the container, which is stopped unless leaveRunning is set.
+func checkpoint(ctx context.Context, nameOrId string, r *checkpointReq) (*entities.CheckpointReport, error) {
+	if r == nil {
+		r = &checkpointReq{}
+	}
+	report, err := adapter.ContainerCheckpoint(ctx, nameOrId, checkpointOptions(r), nil)
+	checkCriuFailure(err)
+	return report, err
+}
+
+// restore restores the checkpointed container in place.
+func restore(ctx context.Context, nameOrId string, r *restoreReq) (*entities.RestoreReport, error) {
+	if r == nil {
+		r = &restoreReq{}
+	}
+	report, err := adapter.ContainerRestore(ctx, nameOrId, restoreOptions(r), nil)
+	checkCriuFailure(err)
+	return report, err
+}
+
+func checkpointOptions(r *checkpointReq) *containers.CheckpointOptions {
+	return (&containers.CheckpointOptions{}).
+		WithKeep(r.Keep).
+		WithLeaveRunning(r.LeaveRunning).
+		WithTCPEstablished(r.TcpEstablished)
+}
+
+func restoreOptions(r *restoreReq) *containers.RestoreOptions {
+	opts := (&containers.RestoreOptions{}).
+		WithKeep(r.Keep).
+		WithTCPEstablished(r.TcpEstablished)
+	if r.IgnoreStaticIp {
+		opts.WithIgnoreStaticIP(true)
+	}
+	if r.IgnoreStaticMac {
+		opts.WithIgnoreStaticMAC(true)
+	}
+	return opts
+}
+
+// checkCriuFailure remembers the error if it shows CRIU is missing or broken on the host,
+// and forgets it once a checkpoint or restore succeeds.
+func checkCriuFailure(err error) {
+	criuFailureMutex.Lock()
+	defer criuFailureMutex.Unlock()
+
+	if err == nil {
+		criuFailure = ""
+		return
+	}
+	msg := strings.ToLower(err.Error())
+	for _, f := range criuHostFailures {
+		if strings.Contains(msg, f) {
+			criuFailure = err.Error()
+			return
+		}
+	}
+}
+
+// checkpointSupport detects whether checkpointing is supported by the host.
+// CRIU is not reported by Podman, so it is guessed from the runtime, and from previous failures.
+func checkpointSupport(ctx context.Context) (*checkpointSupportResp, error) {
+	info, err := adapter.SystemInfo(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Host != nil {
+		if info.Host.Security.Rootless {
+			return &checkpointSupportResp{
+				Status: checkpointUnavailable,
+				Reason: "checkpointing requires rootful Podman",
+			}, nil
+		}
+		if rt := info.Host.OCIRuntime; rt != nil && rt.Name == "crun" && strings.Contains(rt.Version, "-CRIU") {
+			return &checkpointSupportResp{
+				Status: checkpointUnavailable,
+				Reason: "crun is built without CRIU support",
+			}, nil
+		}
+	}
+
+	criuFailureMutex.Lock()
+	failure := criuFailure
+	criuFailureMutex.Unlock()
+	if failure != "" {
+		return &checkpointSupportResp{
+			Status: checkpointUnavailable,
+			Reason: failure,
+		}, nil
+	}
+
+	if info.Host != nil {
+		if rt := info.Host.OCIRuntime; rt != nil && rt.Name == "crun" && strings.Contains(rt.Version, "+CRIU") {
+			return &checkpointSupportResp{Status: checkpointAvailable}, nil
+		}
+	}
+	return &checkpointSupportResp{
+		Status: checkpointUnknown,
+		Reason: "CRIU cannot be detected with the OCI runtime, it is known after the first checkpoint",
+	}, nil
+}
@@ -0,0 +1,73 @@
+package container
+
+import (
+	"containerup/adapter"
+	"context"
+)
+
+type cloneReq struct {
+	Name      string             `json:"name"` // `<name>-clone` by default
+	Image     *string            `json:"image"`
+	Env       map[string]*string `json:"env"`   // null to remove
+	Ports     *[]*portReq        `json:"ports"` // host ports are randomly assigned by default, not to conflict with the container
+	Volumes   *[]*volumeReq      `json:"volumes"`
+	Resources *resReq            `json:"resources"`
+	Start     *bool              `json:"start"` // same as the running state of the container by default
+}
+
+// cloneCreateReq builds the creation request of a new container with the same configuration as the existing one,
+// with overrides applied.
+func cloneCreateReq(ctx context.Context, nameOrId string, r *cloneReq) (*createReq, error) {
+	data, err := adapter.ContainerInspect(ctx, nameOrId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := createReqFromInspect(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		r = &cloneReq{}
+	}
+
+	c.Name = r.Name
+	if c.Name == "" {
+		c.Name = data.Name + "-clone"
+	}
+	if r.Image != nil {
+		c.Image = *r.Image
+	}
+	for k, v := range r.Env {
+		if v == nil {
+			delete(c.Env, k)
+			continue
+		}
+		if c.Env == nil {
+			c.Env = map[string]string{}
+		}
+		c.Env[k] = *v
+	}
+	if r.Ports != nil {
+		c.Ports = *r.Ports
+	} else {
+		// the host ports are still bound by the container
+		for _, p := range c.Ports {
+			for _, h := range p.Host {
+				h.Port = 0
+			}
+		}
+	}
+	if r.Volumes != nil {
+		c.Volumes = *r.Volumes
+	}
+	if r.Resources != nil {
+		c.Resources = r.Resources
+	}
+	if r.Start != nil {
+		c.Start = *r.Start
+	}
+
+	return c, nil
+}
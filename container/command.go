@@ -0,0 +1,591 @@
+package container
+
+import (
+	"containerup/conn"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/go-units"
+	"github.com/mattn/go-shellwords"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type fromCommandReq struct {
+	Command string `json:"command"`
+}
+
+// runFlag is an option of podman-run supported to be converted into the creation request.
+type runFlag struct {
+	long   string
+	short  byte
+	isBool bool
+	apply  func(c *createReq, val string) error
+	// the flag is ignored with the warning if set, instead of applied
+	warning string
+}
+
+var runFlags = []*runFlag{
+	{long: "name", apply: func(c *createReq, val string) error {
+		c.Name = val
+		return nil
+	}},
+	{long: "detach", short: 'd', isBool: true, apply: func(c *createReq, val string) error {
+		// containers are always started in background
+		return nil
+	}},
+	{long: "interactive", short: 'i', isBool: true, warning: "--interactive is ignored, stdin of the container is not kept open"},
+	{long: "tty", short: 't', isBool: true, warning: "--tty is ignored, the container is created without a TTY"},
+	{long: "rm", isBool: true, warning: "--rm is ignored, the container is kept after it exits"},
+	{long: "env", short: 'e', apply: func(c *createReq, val string) error {
+		k, v, ok := strings.Cut(val, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("env %s must be in format key=value", val)
+		}
+		if c.Env == nil {
+			c.Env = map[string]string{}
+		}
+		c.Env[k] = v
+		return nil
+	}},
+	{long: "label", short: 'l', apply: func(c *createReq, val string) error {
+		k, v, _ := strings.Cut(val, "=")
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[k] = v
+		return nil
+	}},
+	{long: "annotation", apply: func(c *createReq, val string) error {
+		k, v, ok := strings.Cut(val, "=")
+		if !ok {
+			return fmt.Errorf("annotation %s must be in format key=value", val)
+		}
+		if c.Annotations == nil {
+			c.Annotations = map[string]string{}
+		}
+		c.Annotations[k] = v
+		return nil
+	}},
+	{long: "workdir", short: 'w', apply: func(c *createReq, val string) error {
+		c.WorkDir = &val
+		return nil
+	}},
+	{long: "user", short: 'u', apply: func(c *createReq, val string) error {
+		c.User = &val
+		return nil
+	}},
+	{long: "hostname", short: 'h', apply: func(c *createReq, val string) error {
+		c.Hostname = &val
+		return nil
+	}},
+	{long: "entrypoint", apply: func(c *createReq, val string) error {
+		// the entrypoint can be a JSON array
+		var entrypoint []string
+		if err := json.Unmarshal([]byte(val), &entrypoint); err == nil {
+			val = shellJoin(entrypoint)
+		} else {
+			val = shellJoin([]string{val})
+		}
+		c.Entrypoint = &val
+		return nil
+	}},
+	{long: "volume", short: 'v', apply: applyVolumeFlag},
+	{long: "tmpfs", apply: func(c *createReq, val string) error {
+		dest, options, _ := strings.Cut(val, ":")
+		c.Tmpfs = append(c.Tmpfs, &tmpfsReq{Container: dest, Options: options})
+		return nil
+	}},
+	{long: "publish", short: 'p', apply: applyPublishFlag},
+	{long: "cpu-shares", short: 'c', apply: func(c *createReq, val string) error {
+		shares, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid cpu shares %s: %v", val, err)
+		}
+		resources(c).CpuShares = shares
+		return nil
+	}},
+	{long: "cpus", apply: func(c *createReq, val string) error {
+		cpus, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpus %s: %v", val, err)
+		}
+		resources(c).CpuCores = cpus
+		return nil
+	}},
+	{long: "memory", short: 'm', apply: func(c *createReq, val string) error {
+		mb, err := parseMemoryMB(val)
+		if err != nil {
+			return err
+		}
+		resources(c).MemoryMB = mb
+		return nil
+	}},
+	{long: "memory-swap", apply: func(c *createReq, val string) error {
+		mb, err := parseMemoryMB(val)
+		if err != nil {
+			return err
+		}
+		resources(c).MemoryWithSwapMB = mb
+		return nil
+	}},
+	{long: "pids-limit", apply: func(c *createReq, val string) error {
+		limit, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid pids limit %s: %v", val, err)
+		}
+		resources(c).PidsLimit = limit
+		return nil
+	}},
+	{long: "ulimit", apply: func(c *createReq, val string) error {
+		c.Ulimits = append(c.Ulimits, val)
+		return nil
+	}},
+	{long: "cap-add", apply: func(c *createReq, val string) error {
+		c.CapAdd = append(c.CapAdd, val)
+		return nil
+	}},
+	{long: "cap-drop", apply: func(c *createReq, val string) error {
+		c.CapDrop = append(c.CapDrop, val)
+		return nil
+	}},
+	{long: "device", apply: func(c *createReq, val string) error {
+		c.Devices = append(c.Devices, val)
+		return nil
+	}},
+	{long: "dns", apply: func(c *createReq, val string) error {
+		c.DnsServers = append(c.DnsServers, val)
+		return nil
+	}},
+	{long: "dns-search", apply: func(c *createReq, val string) error {
+		c.DnsSearch = append(c.DnsSearch, val)
+		return nil
+	}},
+	{long: "add-host", apply: func(c *createReq, val string) error {
+		c.ExtraHosts = append(c.ExtraHosts, val)
+		return nil
+	}},
+	{long: "stop-signal", apply: func(c *createReq, val string) error {
+		c.StopSignal = &val
+		return nil
+	}},
+	{long: "stop-timeout", apply: func(c *createReq, val string) error {
+		timeout, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid stop timeout %s: %v", val, err)
+		}
+		stopTimeout := uint(timeout)
+		c.StopTimeout = &stopTimeout
+		return nil
+	}},
+	{long: "init", isBool: true, apply: func(c *createReq, val string) error {
+		c.Init = val == "true"
+		return nil
+	}},
+	{long: "read-only", isBool: true, apply: func(c *createReq, val string) error {
+		c.ReadOnly = val == "true"
+		return nil
+	}},
+	{long: "log-driver", apply: func(c *createReq, val string) error {
+		logOptions(c).Driver = val
+		return nil
+	}},
+	{long: "log-opt", apply: func(c *createReq, val string) error {
+		k, v, ok := strings.Cut(val, "=")
+		if !ok {
+			return fmt.Errorf("log option %s must be in format key=value", val)
+		}
+		logOptions(c).Options[k] = v
+		return nil
+	}},
+	{long: "health-cmd", apply: func(c *createReq, val string) error {
+		healthCheck(c).Command = val
+		return nil
+	}},
+	{long: "health-interval", apply: func(c *createReq, val string) error {
+		healthCheck(c).Interval = val
+		return nil
+	}},
+	{long: "health-timeout", apply: func(c *createReq, val string) error {
+		healthCheck(c).Timeout = val
+		return nil
+	}},
+	{long: "health-retries", apply: func(c *createReq, val string) error {
+		retries, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid health retries %s: %v", val, err)
+		}
+		healthCheck(c).Retries = uint(retries)
+		return nil
+	}},
+	{long: "health-start-period", apply: func(c *createReq, val string) error {
+		healthCheck(c).StartPeriod = val
+		return nil
+	}},
+	{long: "health-on-failure", apply: func(c *createReq, val string) error {
+		healthCheck(c).OnFailure = val
+		return nil
+	}},
+	{long: "no-healthcheck", isBool: true, apply: func(c *createReq, val string) error {
+		if val == "true" {
+			healthCheck(c).Command = "none"
+		}
+		return nil
+	}},
+	{long: "restart", apply: func(c *createReq, val string) error {
+		policy, retries, ok := strings.Cut(val, ":")
+		c.RestartPolicy = &restartPolicyReq{Policy: policy}
+		if ok {
+			maxRetries, err := strconv.ParseUint(retries, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid restart retries %s: %v", retries, err)
+			}
+			c.RestartPolicy.MaxRetries = uint(maxRetries)
+		}
+		return nil
+	}},
+	{long: "network", apply: applyNetworkFlag},
+	{long: "net", apply: applyNetworkFlag},
+	{long: "pod", apply: func(c *createReq, val string) error {
+		c.Pod = val
+		return nil
+	}},
+	{long: "privileged", isBool: true, apply: func(c *createReq, val string) error {
+		c.Privileged = val == "true"
+		return nil
+	}},
+	{long: "security-opt", apply: func(c *createReq, val string) error {
+		c.SecurityOpts = append(c.SecurityOpts, val)
+		return nil
+	}},
+	{long: "shm-size", apply: func(c *createReq, val string) error {
+		mb, err := parseMemoryMB(val)
+		if err != nil {
+			return err
+		}
+		c.ShmSizeMB = mb
+		return nil
+	}},
+}
+
+func CreateFromCommand(w http.ResponseWriter, req *http.Request) {
+	var r fromCommandReq
+	defer req.Body.Close()
+	err := json.NewDecoder(req.Body).Decode(&r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, warnings, err := parseRunCommand(r.Command)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pmConn := conn.GetConn(req.Context())
+	createAndReturn(w, pmConn, c, warnings)
+}
+
+// parseRunCommand converts a command line of `podman run` or `docker run` into the creation request.
+// All the unsupported options are reported in the error, and the options ignored in the warnings.
+func parseRunCommand(cmdline string) (*createReq, []string, error) {
+	// line continuations
+	cmdline = strings.ReplaceAll(cmdline, "\\\r\n", " ")
+	cmdline = strings.ReplaceAll(cmdline, "\\\n", " ")
+
+	args, err := shellwords.Parse(cmdline)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid command: %v", err)
+	}
+
+	if len(args) > 0 && args[0] == "sudo" {
+		args = args[1:]
+	}
+	if len(args) == 0 || (args[0] != "podman" && args[0] != "docker") {
+		return nil, nil, errors.New("command must start with `podman` or `docker`")
+	}
+	args = args[1:]
+	if len(args) > 0 && args[0] == "container" {
+		args = args[1:]
+	}
+
+	c := &createReq{}
+	if len(args) > 0 && args[0] == "run" {
+		c.Start = true
+	} else if len(args) == 0 || args[0] != "create" {
+		return nil, nil, errors.New("only `run` and `create` commands are supported")
+	}
+	args = args[1:]
+
+	var unsupported, invalid, warnings []string
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+
+		var flags []*runFlag
+		var values []string
+		if strings.HasPrefix(arg, "--") {
+			name, val, hasVal := strings.Cut(arg[2:], "=")
+			f := findRunFlag(name, 0)
+			if f == nil {
+				unsupported = append(unsupported, "--"+name)
+				if !hasVal && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") && !looksLikeBoolFlag(name) {
+					// probably the value of the unsupported flag
+					i++
+				}
+				continue
+			}
+			if !hasVal {
+				if f.isBool {
+					val = "true"
+				} else if i+1 < len(args) {
+					i++
+					val = args[i]
+				} else {
+					invalid = append(invalid, fmt.Sprintf("flag --%s needs a value", name))
+					continue
+				}
+			}
+			flags, values = []*runFlag{f}, []string{val}
+		} else {
+			// short flags, which can be combined, e.g. `-dp 80:80`, `-p80:80`
+			for j := 1; j < len(arg); j++ {
+				f := findRunFlag("", arg[j])
+				if f == nil {
+					unsupported = append(unsupported, "-"+string(arg[j]))
+					continue
+				}
+				if f.isBool {
+					flags, values = append(flags, f), append(values, "true")
+					continue
+				}
+
+				val := arg[j+1:]
+				if val == "" {
+					if i+1 >= len(args) {
+						invalid = append(invalid, fmt.Sprintf("flag -%c needs a value", arg[j]))
+						break
+					}
+					i++
+					val = args[i]
+				}
+				flags, values = append(flags, f), append(values, strings.TrimPrefix(val, "="))
+				break
+			}
+		}
+
+		for k, f := range flags {
+			if f.isBool {
+				b, err := strconv.ParseBool(values[k])
+				if err != nil {
+					invalid = append(invalid, fmt.Sprintf("flag --%s: invalid boolean %s", f.long, values[k]))
+					continue
+				}
+				values[k] = strconv.FormatBool(b)
+			}
+			if f.warning != "" {
+				if values[k] == "true" {
+					warnings = append(warnings, f.warning)
+				}
+				continue
+			}
+			if err := f.apply(c, values[k]); err != nil {
+				invalid = append(invalid, err.Error())
+			}
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return nil, nil, fmt.Errorf("unsupported flags: %s", strings.Join(unsupported, ", "))
+	}
+	if len(invalid) > 0 {
+		return nil, nil, fmt.Errorf("invalid flags: %s", strings.Join(invalid, "; "))
+	}
+
+	if i >= len(args) {
+		return nil, nil, errors.New("image is not specified")
+	}
+	c.Image = args[i]
+	if cmds := args[i+1:]; len(cmds) > 0 {
+		cmd := shellJoin(cmds)
+		c.Command = &cmd
+	}
+
+	if c.HealthCheck != nil && c.HealthCheck.Command == "" {
+		return nil, nil, errors.New("health check options need --health-cmd")
+	}
+
+	return c, warnings, nil
+}
+
+func findRunFlag(long string, short byte) *runFlag {
+	for _, f := range runFlags {
+		if (long != "" && f.long == long) || (short != 0 && f.short == short) {
+			return f
+		}
+	}
+	return nil
+}
+
+// looksLikeBoolFlag guesses whether an unsupported flag takes no value, to skip the value of the other flags.
+func looksLikeBoolFlag(name string) bool {
+	switch name {
+	case "replace", "quiet", "systemd", "sig-proxy", "no-hosts",
+		"oom-kill-disable", "read-only-tmpfs", "rootfs", "publish-all", "http-proxy", "env-host", "tls-verify":
+		return true
+	}
+	return false
+}
+
+func applyVolumeFlag(c *createReq, val string) error {
+	parts := strings.Split(val, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return fmt.Errorf("unsupported volume %s, should be in format source:destination[:options]", val)
+	}
+
+	v := &volumeReq{
+		Type:      "volume",
+		Container: parts[1],
+		Host:      parts[0],
+		ReadWrite: "rw",
+	}
+	if strings.HasPrefix(v.Host, "/") || strings.HasPrefix(v.Host, ".") {
+		v.Type = "bind"
+	}
+	if len(parts) == 3 {
+		for _, opt := range strings.Split(parts[2], ",") {
+			switch opt {
+			case "ro", "rw":
+				v.ReadWrite = opt
+			default:
+				return fmt.Errorf("unsupported volume option %s of %s", opt, val)
+			}
+		}
+	}
+	c.Volumes = append(c.Volumes, v)
+	return nil
+}
+
+func applyPublishFlag(c *createReq, val string) error {
+	mapping, protocol, _ := strings.Cut(val, "/")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	hostIp := ""
+	if strings.HasPrefix(mapping, "[") {
+		// IPv6 address
+		end := strings.Index(mapping, "]:")
+		if end < 0 {
+			return fmt.Errorf("invalid port mapping %s", val)
+		}
+		hostIp = mapping[1:end]
+		mapping = mapping[end+2:]
+	}
+
+	parts := strings.Split(mapping, ":")
+	hostPortStr, ctnPortStr := "", ""
+	switch {
+	case len(parts) == 1:
+		ctnPortStr = parts[0]
+	case len(parts) == 2:
+		hostPortStr, ctnPortStr = parts[0], parts[1]
+	case len(parts) == 3 && hostIp == "":
+		hostIp, hostPortStr, ctnPortStr = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("invalid port mapping %s", val)
+	}
+
+	if strings.Contains(hostPortStr, "-") || strings.Contains(ctnPortStr, "-") {
+		return fmt.Errorf("unsupported port range %s", val)
+	}
+	ctnPort, err := strconv.ParseUint(ctnPortStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid container port of %s: %v", val, err)
+	}
+	hostPort := uint64(0)
+	if hostPortStr != "" {
+		hostPort, err = strconv.ParseUint(hostPortStr, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid host port of %s: %v", val, err)
+		}
+	}
+
+	host := &portHost{Addr: hostIp, Port: uint16(hostPort)}
+	for _, p := range c.Ports {
+		if p.Container == uint16(ctnPort) && p.Protocol == protocol {
+			p.Host = append(p.Host, host)
+			return nil
+		}
+	}
+	c.Ports = append(c.Ports, &portReq{
+		Container: uint16(ctnPort),
+		Host:      []*portHost{host},
+		Protocol:  protocol,
+	})
+	return nil
+}
+
+// applyNetworkFlag applies a network mode like podman-run, or the networks to join in bridge mode.
+func applyNetworkFlag(c *createReq, val string) error {
+	if c.Network == nil {
+		c.Network = &networkReq{}
+	}
+	mode, _, _ := strings.Cut(val, ":")
+	switch mode {
+	case "bridge", "host", "none", "private", "slirp4netns", "pasta", "container", "ns":
+		if mode == "bridge" && val != mode {
+			return fmt.Errorf("unsupported network options of %s", val)
+		}
+		c.Network.Mode = val
+		return nil
+	}
+	for _, network := range strings.Split(val, ",") {
+		if strings.Contains(network, ":") {
+			return fmt.Errorf("unsupported network options of %s", network)
+		}
+		c.Network.Networks = append(c.Network.Networks, network)
+	}
+	return nil
+}
+
+func parseMemoryMB(val string) (int, error) {
+	b, err := units.RAMInBytes(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory %s: %v", val, err)
+	}
+	if b%(1024*1024) != 0 {
+		return 0, fmt.Errorf("unsupported memory %s, should be in MiB", val)
+	}
+	return int(b / 1024 / 1024), nil
+}
+
+func resources(c *createReq) *resReq {
+	if c.Resources == nil {
+		c.Resources = &resReq{}
+	}
+	return c.Resources
+}
+
+func logOptions(c *createReq) *logReq {
+	if c.Log == nil {
+		c.Log = &logReq{Options: map[string]string{}}
+	}
+	return c.Log
+}
+
+func healthCheck(c *createReq) *healthCheckReq {
+	if c.HealthCheck == nil {
+		c.HealthCheck = &healthCheckReq{}
+	}
+	return c.HealthCheck
+}
@@ -0,0 +1,213 @@
+package container
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRunCommand(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		cmdline  string
+		want     *createReq
+		warnings []string
+		err      []string // substrings of the error, if failed
+	}{
+		{
+			name:    "run with separate values",
+			cmdline: "podman run -d --name web -p 8080:80 nginx",
+			want: &createReq{
+				Name:  "web",
+				Image: "nginx",
+				Ports: []*portReq{{Container: 80, Host: []*portHost{{Port: 8080}}, Protocol: "tcp"}},
+				Start: true,
+			},
+		},
+		{
+			name:    "flag=value",
+			cmdline: "podman run --name=web --publish=127.0.0.1:8080:80/udp nginx",
+			want: &createReq{
+				Name:  "web",
+				Image: "nginx",
+				Ports: []*portReq{{Container: 80, Host: []*portHost{{Addr: "127.0.0.1", Port: 8080}}, Protocol: "udp"}},
+				Start: true,
+			},
+		},
+		{
+			name:    "combined and attached short flags",
+			cmdline: "docker run -dp 80:80 -eFOO=bar -m=512m nginx",
+			want: &createReq{
+				Image:     "nginx",
+				Env:       map[string]string{"FOO": "bar"},
+				Ports:     []*portReq{{Container: 80, Host: []*portHost{{Port: 80}}, Protocol: "tcp"}},
+				Resources: &resReq{MemoryMB: 512},
+				Start:     true,
+			},
+		},
+		{
+			name:    "quoting",
+			cmdline: `docker run -e 'GREETING=hello world' --entrypoint "/bin/sh" alpine -c "echo 'hi there'"`,
+			want: &createReq{
+				Image:      "alpine",
+				Env:        map[string]string{"GREETING": "hello world"},
+				Entrypoint: str("/bin/sh"),
+				Command:    str(`-c 'echo '\''hi there'\'''`),
+				Start:      true,
+			},
+		},
+		{
+			name:    "JSON entrypoint",
+			cmdline: `podman create --entrypoint '["/bin/sh", "-c"]' alpine`,
+			want: &createReq{
+				Image:      "alpine",
+				Entrypoint: str("/bin/sh -c"),
+			},
+		},
+		{
+			name:    "line continuations, sudo and the container subcommand",
+			cmdline: "sudo podman container run \\\n  --name web \\\r\n  -v /data:/data:ro -v cache:/var/cache \\\n  nginx",
+			want: &createReq{
+				Name:  "web",
+				Image: "nginx",
+				Volumes: []*volumeReq{
+					{Type: "bind", Container: "/data", Host: "/data", ReadWrite: "ro"},
+					{Type: "volume", Container: "/var/cache", Host: "cache", ReadWrite: "rw"},
+				},
+				Start: true,
+			},
+		},
+		{
+			name:    "bool flags with values",
+			cmdline: "podman create --init=false --read-only nginx",
+			want: &createReq{
+				Image:    "nginx",
+				ReadOnly: true,
+			},
+		},
+		{
+			name:    "end of flags",
+			cmdline: "podman run --restart on-failure:3 -- nginx -g 'daemon off;'",
+			want: &createReq{
+				Image:         "nginx",
+				Command:       str(`-g 'daemon off;'`),
+				RestartPolicy: &restartPolicyReq{Policy: "on-failure", MaxRetries: 3},
+				Start:         true,
+			},
+		},
+		{
+			name:    "network, pod and security flags",
+			cmdline: "podman run --network host --pod app --privileged --security-opt label=disable --shm-size 256m nginx",
+			want: &createReq{
+				Image:        "nginx",
+				Network:      &networkReq{Mode: "host"},
+				Pod:          "app",
+				Privileged:   true,
+				SecurityOpts: []string{"label=disable"},
+				ShmSizeMB:    256,
+				Start:        true,
+			},
+		},
+		{
+			name:    "networks to join",
+			cmdline: "docker run --net=frontend,backend --network monitoring nginx",
+			want: &createReq{
+				Image:   "nginx",
+				Network: &networkReq{Networks: []string{"frontend", "backend", "monitoring"}},
+				Start:   true,
+			},
+		},
+		{
+			name:    "ignored flags",
+			cmdline: "docker run -it --rm --interactive=false --name web nginx",
+			want:    &createReq{Name: "web", Image: "nginx", Start: true},
+			warnings: []string{
+				"--interactive is ignored, stdin of the container is not kept open",
+				"--tty is ignored, the container is created without a TTY",
+				"--rm is ignored, the container is kept after it exits",
+			},
+		},
+		{
+			name:    "unsupported flags",
+			cmdline: "podman run --cidfile /tmp/id --userns=keep-id -P --systemd nginx",
+			err:     []string{"unsupported flags: --cidfile, --userns, -P, --systemd"},
+		},
+		{
+			name:    "network options",
+			cmdline: "podman run --network backend:ip=10.0.0.2 nginx",
+			err:     []string{"unsupported network options of backend:ip=10.0.0.2"},
+		},
+		{
+			name:    "missing value",
+			cmdline: "podman run nginx --name",
+			want: &createReq{
+				Image:   "nginx",
+				Command: str("--name"),
+				Start:   true,
+			},
+		},
+		{
+			name:    "missing value at the end",
+			cmdline: "podman run --name",
+			err:     []string{"flag --name needs a value"},
+		},
+		{
+			name:    "invalid values",
+			cmdline: "podman run -p 80-81:80 --memory 1.5k --read-only=maybe nginx",
+			err:     []string{"invalid flags", "unsupported port range", "unsupported memory 1.5k", "invalid boolean"},
+		},
+		{
+			name:    "unterminated quote",
+			cmdline: `podman run -e "FOO=bar nginx`,
+			err:     []string{"invalid command"},
+		},
+		{
+			name:    "other program",
+			cmdline: "kubectl run web --image nginx",
+			err:     []string{"must start with `podman` or `docker`"},
+		},
+		{
+			name:    "other subcommand",
+			cmdline: "podman start web",
+			err:     []string{"only `run` and `create`"},
+		},
+		{
+			name:    "no image",
+			cmdline: "podman run -d",
+			err:     []string{"image is not specified"},
+		},
+		{
+			name:    "health options without command",
+			cmdline: "podman run --health-interval 10s nginx",
+			err:     []string{"need --health-cmd"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := parseRunCommand(tt.cmdline)
+			if len(tt.err) > 0 {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				for _, e := range tt.err {
+					if !strings.Contains(err.Error(), e) {
+						t.Errorf("error %q does not contain %q", err, e)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("got warnings %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
@@ -4,18 +4,29 @@ import (
 	"containerup/adapter"
 	"containerup/conn"
 	"containerup/utils"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	nettypes "github.com/containers/common/libnetwork/types"
+	"github.com/containers/common/pkg/signal"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/podman/v4/libpod/define"
 	"github.com/containers/podman/v4/pkg/specgen"
 	"github.com/containers/podman/v4/pkg/util"
+	"github.com/docker/go-units"
 	"github.com/mattn/go-shellwords"
 	spec "github.com/opencontainers/runtime-spec/specs-go"
+	"net"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type volumeReq struct {
+	Type      string `json:"type"` // bind by default, or volume
 	Container string `json:"container"`
 	Host      string `json:"host"`
 	ReadWrite string `json:"readWrite"`
@@ -37,19 +48,75 @@ type resReq struct {
 	CpuCores         float64 `json:"cpuCores"`
 	MemoryMB         int     `json:"memoryMB"`
 	MemoryWithSwapMB int     `json:"memorySwapMB"`
+	PidsLimit        int64   `json:"pidsLimit"` // -1 for unlimited
+}
+
+type healthCheckReq struct {
+	Command     string `json:"command"`
+	Interval    string `json:"interval"`
+	Timeout     string `json:"timeout"`
+	Retries     uint   `json:"retries"`
+	StartPeriod string `json:"startPeriod"`
+	OnFailure   string `json:"onFailure"`
+}
+
+type tmpfsReq struct {
+	Container string `json:"container"`
+	Options   string `json:"options"`
+}
+
+type logReq struct {
+	Driver  string            `json:"driver"`
+	Options map[string]string `json:"options"`
+}
+
+type restartPolicyReq struct {
+	Policy     string `json:"policy"`
+	MaxRetries uint   `json:"maxRetries"`
+}
+
+type networkReq struct {
+	// bridge, host, none, private, slirp4netns, pasta, container:ID or ns:PATH, the same as podman-run
+	Mode     string   `json:"mode"`
+	Networks []string `json:"networks"` // to join in bridge mode
 }
 
 type createReq struct {
 	Name          string            `json:"name"`
 	Image         string            `json:"image"`
 	Command       *string           `json:"command"`
+	Entrypoint    *string           `json:"entrypoint"`
 	WorkDir       *string           `json:"workDir"`
+	User          *string           `json:"user"`
+	Hostname      *string           `json:"hostname"`
 	Env           map[string]string `json:"env"`
+	Labels        map[string]string `json:"labels"`
+	Annotations   map[string]string `json:"annotations"`
 	Volumes       []*volumeReq      `json:"volumes"`
+	Tmpfs         []*tmpfsReq       `json:"tmpfs"`
 	Ports         []*portReq        `json:"ports"`
 	Resources     *resReq           `json:"resources"`
+	Ulimits       []string          `json:"ulimits"`
+	CapAdd        []string          `json:"capAdd"`
+	CapDrop       []string          `json:"capDrop"`
+	Devices       []string          `json:"devices"`
+	DnsServers    []string          `json:"dnsServers"`
+	DnsSearch     []string          `json:"dnsSearch"`
+	ExtraHosts    []string          `json:"extraHosts"`
+	StopSignal    *string           `json:"stopSignal"`
+	StopTimeout   *uint             `json:"stopTimeout"`
+	Init          bool              `json:"init"`
+	ReadOnly      bool              `json:"readOnly"`
+	Log           *logReq           `json:"log"`
+	HealthCheck   *healthCheckReq   `json:"healthCheck"`
+	RestartPolicy *restartPolicyReq `json:"restartPolicy"`
+	Network       *networkReq       `json:"network"`
+	Pod           string            `json:"pod"`
+	Privileged    bool              `json:"privileged"`
+	SecurityOpts  []string          `json:"securityOpts"`
+	ShmSizeMB     int               `json:"shmSizeMB"`
 	Start         bool              `json:"start"`
-	AlwaysRestart bool              `json:"alwaysRestart"`
+	AlwaysRestart bool              `json:"alwaysRestart"` // legacy, replaced by RestartPolicy
 }
 
 func Create(w http.ResponseWriter, req *http.Request) {
@@ -62,7 +129,48 @@ func Create(w http.ResponseWriter, req *http.Request) {
 	}
 
 	pmConn := conn.GetConn(req.Context())
+	if req.URL.Query().Get("dryRun") == "1" {
+		dryRunAndReturn(w, pmConn, &c)
+		return
+	}
+	createAndReturn(w, pmConn, &c, nil)
+}
 
+// createAndReturn creates and optionally starts the container by the request, and writes the result with the warnings.
+func createAndReturn(w http.ResponseWriter, ctx context.Context, c *createReq, warnings []string) {
+	s, err := buildSpec(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := adapter.ContainerCreateWithSpec(ctx, s, nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot create container: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	startErrStr := ""
+	if c.Start {
+		err = adapter.ContainerStart(ctx, ret.ID, nil)
+		if err != nil {
+			startErrStr = err.Error()
+		}
+	}
+
+	resp := map[string]any{
+		"Id":       ret.ID,
+		"StartErr": startErrStr,
+	}
+	if len(warnings) > 0 {
+		resp["Warnings"] = warnings
+	}
+	utils.Return(w, resp)
+}
+
+// buildSpec converts the creation request into the spec of Podman.
+// The equivalent podman command is set to ContainerCreateCommand of the spec.
+func buildSpec(c *createReq) (*specgen.SpecGenerator, error) {
 	createCmd := []string{"podman", "create"}
 	if c.Start {
 		createCmd = []string{"podman", "run", "-d"}
@@ -70,36 +178,105 @@ func Create(w http.ResponseWriter, req *http.Request) {
 
 	s := specgen.NewSpecGenerator(c.Image, false)
 	s.Name = c.Name
-	createCmd = append(createCmd, "--name", s.Name)
+	if s.Name != "" {
+		createCmd = append(createCmd, "--name", s.Name)
+	}
 
+	if c.User != nil {
+		s.User = *c.User
+		createCmd = append(createCmd, "--user", s.User)
+	}
+	if c.Hostname != nil {
+		s.Hostname = *c.Hostname
+		createCmd = append(createCmd, "--hostname", s.Hostname)
+	}
 	if c.WorkDir != nil {
 		s.WorkDir = *c.WorkDir
 		createCmd = append(createCmd, "--workdir", s.WorkDir)
 	}
+	if c.Entrypoint != nil {
+		entrypoint, err := shellwords.Parse(*c.Entrypoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entrypoint: %v", err)
+		}
+		// an empty entrypoint resets the one of the image
+		s.Entrypoint = []string{}
+		arg := ""
+		if len(entrypoint) > 0 {
+			s.Entrypoint = entrypoint
+			entrypointJson, _ := json.Marshal(entrypoint)
+			arg = string(entrypointJson)
+		}
+		createCmd = append(createCmd, "--entrypoint", arg)
+	}
 	if len(c.Env) > 0 {
 		s.Env = c.Env
-		for k, v := range s.Env {
-			createCmd = append(createCmd, "--env", fmt.Sprintf("%s=%s", k, v))
+		for _, k := range sortedKeys(s.Env) {
+			createCmd = append(createCmd, "--env", fmt.Sprintf("%s=%s", k, s.Env[k]))
+		}
+	}
+	if len(c.Labels) > 0 {
+		s.Labels = c.Labels
+		for _, k := range sortedKeys(s.Labels) {
+			createCmd = append(createCmd, "--label", fmt.Sprintf("%s=%s", k, s.Labels[k]))
+		}
+	}
+	if len(c.Annotations) > 0 {
+		s.Annotations = c.Annotations
+		for _, k := range sortedKeys(s.Annotations) {
+			createCmd = append(createCmd, "--annotation", fmt.Sprintf("%s=%s", k, s.Annotations[k]))
 		}
 	}
 	if len(c.Volumes) > 0 {
 		var mounts []spec.Mount
 		for _, v := range c.Volumes {
 			if v.ReadWrite != "ro" && v.ReadWrite != "rw" {
-				http.Error(w, fmt.Sprintf("Invalid volume option: %s", v.ReadWrite), http.StatusBadRequest)
-				return
+				return nil, fmt.Errorf("invalid volume option: %s", v.ReadWrite)
 			}
 
-			mounts = append(mounts, spec.Mount{
-				Destination: v.Container,
-				Type:        "bind",
-				Source:      v.Host,
-				Options:     []string{v.ReadWrite},
-			})
+			switch v.Type {
+			case "", "bind":
+				mounts = append(mounts, spec.Mount{
+					Destination: v.Container,
+					Type:        "bind",
+					Source:      v.Host,
+					Options:     []string{v.ReadWrite},
+				})
+			case "volume":
+				// the host is the name of the volume
+				s.Volumes = append(s.Volumes, &specgen.NamedVolume{
+					Name:    v.Host,
+					Dest:    v.Container,
+					Options: []string{v.ReadWrite},
+				})
+			default:
+				return nil, fmt.Errorf("invalid volume type: %s", v.Type)
+			}
 			createCmd = append(createCmd, "--volume", fmt.Sprintf("%s:%s:%s", v.Host, v.Container, v.ReadWrite))
 		}
 		s.Mounts = mounts
 	}
+	if len(c.Tmpfs) > 0 {
+		for _, t := range c.Tmpfs {
+			if !strings.HasPrefix(t.Container, "/") {
+				return nil, fmt.Errorf("invalid tmpfs path: %s", t.Container)
+			}
+
+			var options []string
+			arg := t.Container
+			if t.Options != "" {
+				options = strings.Split(t.Options, ",")
+				arg += ":" + t.Options
+			}
+			s.Mounts = append(s.Mounts, spec.Mount{
+				Destination: t.Container,
+				Type:        define.TypeTmpfs,
+				Source:      define.TypeTmpfs,
+				Options:     options,
+			})
+			createCmd = append(createCmd, "--tmpfs", arg)
+		}
+	}
 	if len(c.Ports) > 0 {
 		var ports []nettypes.PortMapping
 		for _, p := range c.Ports {
@@ -161,13 +338,158 @@ func Create(w http.ResponseWriter, req *http.Request) {
 			resLimit.Memory = limitMem
 		}
 
-		if hasCpuLimit || hasMemoryLimit {
+		if res.PidsLimit != 0 {
+			resLimit.Pids = &spec.LinuxPids{Limit: res.PidsLimit}
+			createCmd = append(createCmd, "--pids-limit", strconv.FormatInt(res.PidsLimit, 10))
+		}
+
+		if hasCpuLimit || hasMemoryLimit || resLimit.Pids != nil {
 			s.ResourceLimits = resLimit
 		}
 	}
-	if c.AlwaysRestart {
-		s.RestartPolicy = "always"
-		createCmd = append(createCmd, "--restart", "always")
+	if len(c.Ulimits) > 0 {
+		for _, u := range c.Ulimits {
+			ul, err := units.ParseUlimit(u)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ulimit: %v", err)
+			}
+			s.Rlimits = append(s.Rlimits, spec.POSIXRlimit{
+				Type: "RLIMIT_" + strings.ToUpper(ul.Name),
+				Hard: uint64(ul.Hard),
+				Soft: uint64(ul.Soft),
+			})
+			createCmd = append(createCmd, "--ulimit", u)
+		}
+	}
+	if len(c.CapAdd) > 0 {
+		s.CapAdd = c.CapAdd
+		for _, capName := range s.CapAdd {
+			createCmd = append(createCmd, "--cap-add", capName)
+		}
+	}
+	if len(c.CapDrop) > 0 {
+		s.CapDrop = c.CapDrop
+		for _, capName := range s.CapDrop {
+			createCmd = append(createCmd, "--cap-drop", capName)
+		}
+	}
+	if len(c.Devices) > 0 {
+		for _, d := range c.Devices {
+			if !strings.HasPrefix(d, "/") {
+				return nil, fmt.Errorf("invalid device: %s", d)
+			}
+			// the device string is parsed by Podman
+			s.Devices = append(s.Devices, spec.LinuxDevice{Path: d})
+			createCmd = append(createCmd, "--device", d)
+		}
+	}
+	if len(c.DnsServers) > 0 {
+		for _, d := range c.DnsServers {
+			ip := net.ParseIP(d)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid DNS server: %s", d)
+			}
+			s.DNSServers = append(s.DNSServers, ip)
+			createCmd = append(createCmd, "--dns", d)
+		}
+	}
+	if len(c.DnsSearch) > 0 {
+		s.DNSSearch = c.DnsSearch
+		for _, d := range s.DNSSearch {
+			createCmd = append(createCmd, "--dns-search", d)
+		}
+	}
+	if len(c.ExtraHosts) > 0 {
+		for _, h := range c.ExtraHosts {
+			parts := strings.SplitN(h, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+				return nil, fmt.Errorf("invalid extra host: %s", h)
+			}
+			s.HostAdd = append(s.HostAdd, h)
+			createCmd = append(createCmd, "--add-host", h)
+		}
+	}
+	if c.StopSignal != nil {
+		stopSignal, err := signal.ParseSignalNameOrNumber(*c.StopSignal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stop signal: %v", err)
+		}
+		s.StopSignal = &stopSignal
+		createCmd = append(createCmd, "--stop-signal", *c.StopSignal)
+	}
+	if c.StopTimeout != nil {
+		stopTimeout := *c.StopTimeout
+		s.StopTimeout = &stopTimeout
+		createCmd = append(createCmd, "--stop-timeout", strconv.FormatUint(uint64(stopTimeout), 10))
+	}
+	if c.Init {
+		s.Init = true
+		createCmd = append(createCmd, "--init")
+	}
+	if c.ReadOnly {
+		s.ReadOnlyFilesystem = true
+		createCmd = append(createCmd, "--read-only")
+	}
+	if c.Pod != "" {
+		s.Pod = c.Pod
+		createCmd = append(createCmd, "--pod", c.Pod)
+	}
+	if c.Network != nil {
+		args, err := networkConfig(s, c.Network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network: %v", err)
+		}
+		createCmd = append(createCmd, args...)
+	}
+	if c.Privileged {
+		s.Privileged = true
+		createCmd = append(createCmd, "--privileged")
+	}
+	if len(c.SecurityOpts) > 0 {
+		for _, o := range c.SecurityOpts {
+			if err := securityOpt(s, o); err != nil {
+				return nil, err
+			}
+			createCmd = append(createCmd, "--security-opt", o)
+		}
+	}
+	if c.ShmSizeMB > 0 {
+		shmSize := int64(c.ShmSizeMB) * 1024 * 1024
+		s.ShmSize = &shmSize
+		createCmd = append(createCmd, "--shm-size", fmt.Sprintf("%dm", c.ShmSizeMB))
+	}
+	if c.Log != nil {
+		logConfig, args, err := logConfiguration(c.Log)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log options: %v", err)
+		}
+		s.LogConfiguration = logConfig
+		createCmd = append(createCmd, args...)
+	}
+	if c.HealthCheck != nil {
+		hc, onFailure, args, err := healthConfig(c.HealthCheck)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check: %v", err)
+		}
+		s.HealthConfig = hc
+		s.HealthCheckOnFailureAction = onFailure
+		createCmd = append(createCmd, args...)
+	}
+	if c.RestartPolicy != nil {
+		arg, err := restartPolicy(c.RestartPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid restart policy: %v", err)
+		}
+		s.RestartPolicy = c.RestartPolicy.Policy
+		if c.RestartPolicy.MaxRetries > 0 {
+			retries := c.RestartPolicy.MaxRetries
+			s.RestartRetries = &retries
+		}
+		createCmd = append(createCmd, "--restart", arg)
+	} else if c.AlwaysRestart {
+		// compatible with the legacy field
+		s.RestartPolicy = define.RestartPolicyAlways
+		createCmd = append(createCmd, "--restart", define.RestartPolicyAlways)
 	}
 
 	// finally, image and commands
@@ -176,30 +498,247 @@ func Create(w http.ResponseWriter, req *http.Request) {
 	if c.Command != nil {
 		cmds, err := shellwords.Parse(*c.Command)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid command: %v", err), http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("invalid command: %v", err)
 		}
 		s.Command = cmds
 		createCmd = append(createCmd, cmds...)
 	}
 
 	s.ContainerCreateCommand = createCmd
-	ret, err := adapter.ContainerCreateWithSpec(pmConn, s, nil)
+
+	return s, nil
+}
+
+// restartPolicy validates the restart policy, and returns the value of podman-run argument `--restart`.
+func restartPolicy(r *restartPolicyReq) (string, error) {
+	switch r.Policy {
+	case define.RestartPolicyNo, define.RestartPolicyAlways, define.RestartPolicyUnlessStopped:
+		if r.MaxRetries > 0 {
+			return "", errors.New("max retries is only allowed with policy on-failure")
+		}
+		return r.Policy, nil
+	case define.RestartPolicyOnFailure:
+		if r.MaxRetries > 0 {
+			return fmt.Sprintf("%s:%d", r.Policy, r.MaxRetries), nil
+		}
+		return r.Policy, nil
+	default:
+		return "", fmt.Errorf("unrecognized policy: %s", r.Policy)
+	}
+}
+
+// networkConfig sets the network namespace and networks to the spec, and returns the equivalent arguments of podman-run.
+func networkConfig(s *specgen.SpecGenerator, n *networkReq) ([]string, error) {
+	mode := n.Mode
+	if mode == "" && len(n.Networks) > 0 {
+		mode = string(specgen.Bridge)
+	}
+	if len(n.Networks) > 0 && mode != string(specgen.Bridge) {
+		return nil, fmt.Errorf("networks can only be joined in %s mode", specgen.Bridge)
+	}
+
+	name, value, _ := strings.Cut(mode, ":")
+	switch {
+	case mode == string(specgen.Bridge):
+		s.NetNS = specgen.Namespace{NSMode: specgen.Bridge}
+		if len(n.Networks) == 0 {
+			return []string{"--network", mode}, nil
+		}
+		s.Networks = map[string]nettypes.PerNetworkOptions{}
+		for _, network := range n.Networks {
+			if network == "" {
+				return nil, errors.New("the name of a network is empty")
+			}
+			s.Networks[network] = nettypes.PerNetworkOptions{}
+		}
+		return []string{"--network", strings.Join(n.Networks, ",")}, nil
+	case mode == string(specgen.Host), mode == string(specgen.NoNetwork), mode == string(specgen.Private):
+		s.NetNS = specgen.Namespace{NSMode: specgen.NamespaceMode(mode)}
+	case name == string(specgen.Slirp), name == string(specgen.Pasta):
+		s.NetNS = specgen.Namespace{NSMode: specgen.NamespaceMode(name)}
+		if value != "" {
+			s.NetworkOptions = map[string][]string{name: strings.Split(value, ",")}
+		}
+	case name == string(specgen.FromContainer), name == "ns":
+		if value == "" {
+			return nil, fmt.Errorf("%s requires a value", name)
+		}
+		nsMode := specgen.FromContainer
+		if name == "ns" {
+			nsMode = specgen.Path
+		}
+		s.NetNS = specgen.Namespace{NSMode: nsMode, Value: value}
+	default:
+		return nil, fmt.Errorf("unrecognized mode: %s", mode)
+	}
+	return []string{"--network", mode}, nil
+}
+
+// securityOpt sets a security option of podman-run `--security-opt` to the spec.
+func securityOpt(s *specgen.SpecGenerator, o string) error {
+	k, v, _ := strings.Cut(o, "=")
+	switch k {
+	case "no-new-privileges":
+		if v != "" && v != "true" && v != "false" {
+			return fmt.Errorf("invalid security option: %s", o)
+		}
+		s.NoNewPrivileges = v != "false"
+	case "label":
+		if v == "" {
+			return fmt.Errorf("invalid security option: %s", o)
+		}
+		s.SelinuxOpts = append(s.SelinuxOpts, v)
+	case "apparmor":
+		s.ApparmorProfile = v
+	case "seccomp":
+		s.SeccompProfilePath = v
+	case "mask":
+		s.Mask = append(s.Mask, strings.Split(v, ":")...)
+	case "unmask":
+		s.Unmask = append(s.Unmask, strings.Split(v, ":")...)
+	case "proc-opts":
+		s.ProcOpts = append(s.ProcOpts, strings.Split(v, ",")...)
+	default:
+		return fmt.Errorf("unsupported security option: %s", o)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// logConfiguration converts the log request into the config of Podman, with the equivalent arguments of podman-run.
+// Options `path` and `max-size` have their own fields in the config, like what podman-run does.
+func logConfiguration(l *logReq) (*specgen.LogConfig, []string, error) {
+	ret := &specgen.LogConfig{
+		Driver:  l.Driver,
+		Options: map[string]string{},
+	}
+
+	var args []string
+	if l.Driver != "" {
+		args = append(args, "--log-driver", l.Driver)
+	}
+
+	for _, k := range sortedKeys(l.Options) {
+		v := l.Options[k]
+		switch k {
+		case "path":
+			ret.Path = v
+		case "max-size":
+			size, err := units.FromHumanSize(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			ret.Size = size
+		default:
+			ret.Options[k] = v
+		}
+		args = append(args, "--log-opt", fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return ret, args, nil
+}
+
+// healthConfig converts the health check request into the config of Podman, with the equivalent arguments of podman-run.
+// The command is run with the shell, unless it is a JSON array. `none` disables the health check defined in the image.
+func healthConfig(h *healthCheckReq) (*manifest.Schema2HealthConfig, define.HealthCheckOnFailureAction, []string, error) {
+	onFailure, err := define.ParseHealthCheckOnFailureAction(h.OnFailure)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Cannot create container: %v", err), http.StatusInternalServerError)
-		return
+		return nil, onFailure, nil, err
 	}
 
-	startErrStr := ""
-	if c.Start {
-		err = adapter.ContainerStart(pmConn, ret.ID, nil)
-		if err != nil {
-			startErrStr = err.Error()
+	if strings.TrimSpace(h.Command) == "" {
+		return nil, onFailure, nil, errors.New("command is not specified")
+	}
+
+	if strings.ToLower(h.Command) == "none" {
+		hc := &manifest.Schema2HealthConfig{Test: []string{define.HealthConfigTestNone}}
+		return hc, onFailure, []string{"--no-healthcheck"}, nil
+	}
+
+	var cmdArr []string
+	if err := json.Unmarshal([]byte(h.Command), &cmdArr); err == nil {
+		if len(cmdArr) == 0 {
+			return nil, onFailure, nil, errors.New("command is not specified")
 		}
+		cmdArr = append([]string{define.HealthConfigTestCmd}, cmdArr...)
+	} else {
+		cmdArr = []string{define.HealthConfigTestCmdShell, h.Command}
 	}
+	args := []string{"--health-cmd", h.Command}
 
-	utils.Return(w, map[string]any{
-		"Id":       ret.ID,
-		"StartErr": startErrStr,
-	})
+	interval := h.Interval
+	if interval == "" {
+		interval = define.DefaultHealthCheckInterval
+	}
+	if interval == "disable" {
+		interval = "0"
+	}
+	intervalDuration, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, onFailure, nil, fmt.Errorf("invalid interval: %v", err)
+	}
+	if intervalDuration < 0 {
+		return nil, onFailure, nil, errors.New("interval must be 0 seconds or greater")
+	}
+	if h.Interval != "" {
+		args = append(args, "--health-interval", h.Interval)
+	}
+
+	retries := h.Retries
+	if retries == 0 {
+		retries = define.DefaultHealthCheckRetries
+	} else {
+		args = append(args, "--health-retries", strconv.FormatUint(uint64(retries), 10))
+	}
+
+	timeout := h.Timeout
+	if timeout == "" {
+		timeout = define.DefaultHealthCheckTimeout
+	}
+	timeoutDuration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, onFailure, nil, fmt.Errorf("invalid timeout: %v", err)
+	}
+	if timeoutDuration < time.Second {
+		return nil, onFailure, nil, errors.New("timeout must be at least 1 second")
+	}
+	if h.Timeout != "" {
+		args = append(args, "--health-timeout", h.Timeout)
+	}
+
+	startPeriod := h.StartPeriod
+	if startPeriod == "" {
+		startPeriod = define.DefaultHealthCheckStartPeriod
+	}
+	startPeriodDuration, err := time.ParseDuration(startPeriod)
+	if err != nil {
+		return nil, onFailure, nil, fmt.Errorf("invalid start period: %v", err)
+	}
+	if startPeriodDuration < 0 {
+		return nil, onFailure, nil, errors.New("start period must be 0 seconds or greater")
+	}
+	if h.StartPeriod != "" {
+		args = append(args, "--health-start-period", h.StartPeriod)
+	}
+
+	if onFailure != define.HealthCheckOnFailureActionNone {
+		args = append(args, "--health-on-failure", onFailure.String())
+	}
+
+	hc := &manifest.Schema2HealthConfig{
+		Test:        cmdArr,
+		StartPeriod: startPeriodDuration,
+		Interval:    intervalDuration,
+		Timeout:     timeoutDuration,
+		Retries:     int(retries),
+	}
+	return hc, onFailure, args, nil
 }
@@ -5,6 +5,7 @@ import (
 	"containerup/adapter"
 	"containerup/conn"
 	"containerup/login"
+	"containerup/recording"
 	"containerup/utils"
 	"context"
 	"errors"
@@ -15,9 +16,12 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/mattn/go-shellwords"
+	"github.com/moby/term"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -44,20 +48,57 @@ func Exec(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, fmt.Sprintf("invalid command: %v", err), http.StatusBadRequest)
 		return
 	}
+	if len(cmds) == 0 && len(query["command"]) > 0 {
+		// the arguments of Kubernetes clients, with one command=ARG for each
+		cmds = query["command"]
+	}
+	if len(cmds) == 0 && query.Has("profile") {
+		// the shell profile by name, or the default one if empty
+		profile, err := shellProfileOf(pmConn, nameOrId, query.Get("profile"))
+		if err != nil {
+			if utils.IsErr404(err) {
+				http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, errNoSuchShellProfile) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, errContainerNotRunning) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cmds = profile.Command
+		envs = append(append([]string(nil), profile.Env...), envs...)
+		execConfig.User = profile.User
+		execConfig.WorkingDir = profile.Workdir
+	}
 	if len(cmds) == 0 || cmds[0] == "" {
 		http.Error(w, "command is not specified", http.StatusBadRequest)
 		return
 	}
 
+	// one env=KEY=VALUE for each, in addition to the prefixes of cmd
+	for _, e := range query["env"] {
+		if k, _, _ := strings.Cut(e, "="); k == "" {
+			http.Error(w, fmt.Sprintf("invalid env: %s", e), http.StatusBadRequest)
+			return
+		}
+		envs = append(envs, e)
+	}
+
 	execConfig.Cmd = cmds
 	execConfig.Env = envs
 
-	interactive := query.Get("interactive") == "1"
+	interactive := query.Get("interactive") == "1" || query.Get("stdin") == "true"
 	if interactive {
 		execConfig.AttachStdin = true
 	}
 
-	if t := query.Get("tty"); t == "1" {
+	if t := query.Get("tty"); t == "1" || t == "true" {
 		execConfig.Tty = true
 	}
 
@@ -65,6 +106,38 @@ func Exec(w http.ResponseWriter, req *http.Request) {
 		execConfig.User = u
 	}
 
+	if d := query.Get("workdir"); d != "" {
+		execConfig.WorkingDir = d
+	}
+
+	if query.Get("privileged") == "1" {
+		execConfig.Privileged = true
+	}
+
+	if query.Has("detachKeys") {
+		// empty to disable detaching
+		if k := query.Get("detachKeys"); k != "" {
+			if _, err := term.ToBytes(k); err != nil {
+				http.Error(w, fmt.Sprintf("invalid detach keys: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		execConfig.DetachKeys = query.Get("detachKeys")
+	}
+
+	if f := query.Get("preserveFds"); f != "" {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid preserveFds: %s", f), http.StatusBadRequest)
+			return
+		}
+		if n > 0 {
+			// the fds would be those of the service of Podman, which are not exposed by its API
+			http.Error(w, "preserving file descriptors is not supported by the API of Podman", http.StatusBadRequest)
+			return
+		}
+	}
+
 	detach := query.Get("detach") == "1"
 	if detach {
 		execConfig.Detach = true
@@ -73,22 +146,63 @@ func Exec(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	ws, err := upgrader.Upgrade(w, req, nil)
-	if err != nil {
-		// err replied in upgrader.Upgrade
+	// the session lives server-side, and can be reattached with its ID
+	persist := query.Get("persist") == "1"
+	if persist && detach {
+		http.Error(w, "you cannot specify `persist` and `detach` at the same time", http.StatusBadRequest)
 		return
 	}
 
-	if !login.WebsocketAuth(ws, req.Context()) {
+	ws, proto := upgradeExec(w, req)
+	if ws == nil {
 		return
 	}
 
 	//log.Printf("exec start...")
 	//defer log.Printf("exec end")
 
+	if persist && proto != nativeExecProtocol {
+		// the ID of the terminal is sent on a channel unknown to Kubernetes clients
+		proto.fail(ws, errors.New("`persist` is not supported with the Kubernetes subprotocol"))
+		return
+	}
+	if persist && terminalCount() >= maxTerminals {
+		proto.fail(ws, errTooManyTerminals)
+		return
+	}
+
+	var rec *recording.Recorder
+	if !detach && recording.Enabled(req) {
+		rec, err = recording.Start(recording.Tags{
+			User:      login.Username(),
+			Container: nameOrId,
+			Remote:    req.RemoteAddr,
+		}, cmds)
+		if err != nil {
+			proto.fail(ws, fmt.Errorf("cannot record the session: %v", err))
+			return
+		}
+	}
+
 	sessionId, err := adapter.ContainerExecCreate(pmConn, nameOrId, execConfig)
 	if err != nil {
-		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4002, err.Error()))
+		rec.Close(-1)
+		proto.fail(ws, err)
+		return
+	}
+
+	if persist {
+		t, err := startTerminal(conn.GetDetachedConn(req.Context()), sessionId, nameOrId, cmds, interactive, rec)
+		if err != nil {
+			rec.Close(-1)
+			proto.fail(ws, err)
+			return
+		}
+		if err := sendTerminalId(ws, t); err != nil {
+			// the client may reattach later
+			log.Printf("send terminal id err: %v", err)
+		}
+		serveTerminal(t, ws, proto, interactive)
 		return
 	}
 
@@ -115,14 +229,14 @@ func Exec(w http.ResponseWriter, req *http.Request) {
 	if detach {
 		err = adapter.ContainerExecStart(pmConn, sessionId, nil)
 		if err != nil {
-			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4002, err.Error()))
+			proto.fail(ws, err)
 			return
 		}
 		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 		return
 	}
 
-	pmConn, stopByServer, waitEnd := execTransmitter(pmConn, ws, sessionId, stdOutReader, stdErrReader, stdInWriter)
+	pmConn, stopByServer, waitEnd := execTransmitter(pmConn, ws, proto, execResizer(sessionId), stdOutReader, stdErrReader, stdInWriter, rec)
 
 	err = adapter.ContainerExecStartAndAttach(pmConn, sessionId, startOpts)
 
@@ -138,11 +252,22 @@ func Exec(w http.ResponseWriter, req *http.Request) {
 	}
 
 	stopByServer(err, exitCode)
+	rec.Close(exitCode)
 
 	waitEnd()
 }
 
-func execTransmitter(pmConn context.Context, ws *websocket.Conn, sessionId string, stdOutReader, stdErrReader io.ReadCloser, stdInWriter io.WriteCloser) (context.Context, func(error, int), func()) {
+// execResizer resizes the TTY of the exec session.
+func execResizer(sessionId string) func(context.Context, int, int) error {
+	return func(ctx context.Context, w, h int) error {
+		return adapter.ContainerResizeExecTTY(ctx, sessionId, &containers.ResizeExecTTYOptions{
+			Height: &h,
+			Width:  &w,
+		})
+	}
+}
+
+func execTransmitter(pmConn context.Context, ws *websocket.Conn, proto *execProtocol, resize func(context.Context, int, int) error, stdOutReader, stdErrReader io.ReadCloser, stdInWriter io.WriteCloser, rec execRecorder) (context.Context, func(error, int), func()) {
 	pmConn, cancel := context.WithCancel(pmConn)
 
 	var wgWsReader, wgWsWriter, wgOutputReader sync.WaitGroup
@@ -165,18 +290,7 @@ func execTransmitter(pmConn context.Context, ws *websocket.Conn, sessionId strin
 		close(chWrite)
 		wgWsWriter.Wait()
 
-		wsCode := websocket.CloseNormalClosure
-		text := fmt.Sprintf("ExitCode %d", exitCode)
-		if err != nil {
-			wsCode = websocket.CloseInternalServerErr
-			text = err.Error()
-		}
-		err = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, text))
-		if err != nil && utils.IsWsCloseMsgTooLong(err) {
-			// error msg is too long to be sent in closeMsg
-			//ws.WriteMessage(websocket.TextMessage, []byte("e"+text)) // not yet implemented in the front-end
-			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, ""))
-		}
+		proto.finish(ws, err, exitCode)
 	}
 
 	stopByClient := func(err error) {
@@ -205,20 +319,18 @@ func execTransmitter(pmConn context.Context, ws *websocket.Conn, sessionId strin
 				_, data, err1 = ws.ReadMessage()
 				if len(data) > 0 {
 					switch data[0] {
-					case '1':
+					case proto.stdin:
+						rec.Input(data[1:])
 						_, err2 = stdInWriter.Write(data[1:])
-					case 'r':
-						if len(data) != 5 {
+					case proto.resize:
+						var w, h int
+						w, h, err2 = proto.parseResize(data[1:])
+						if err2 != nil {
 							log.Printf("malformed data: %d", len(data))
-							err2 = errMalformedData
 							break
 						}
-						w := int(data[1])*256 + int(data[2])
-						h := int(data[3])*256 + int(data[4])
-						err2 = adapter.ContainerResizeExecTTY(pmConn, sessionId, &containers.ResizeExecTTYOptions{
-							Height: &h,
-							Width:  &w,
-						})
+						rec.Resize(w, h)
+						err2 = resize(pmConn, w, h)
 					default:
 						err2 = errMalformedData
 					}
@@ -281,9 +393,10 @@ func execTransmitter(pmConn context.Context, ws *websocket.Conn, sessionId strin
 		var n int
 		for {
 			buf := make([]byte, 1025)
-			buf[0] = '1'
+			buf[0] = proto.stdout
 			n, err = stdOutReader.Read(buf[1:])
 			if n > 0 {
+				rec.Output(buf[1 : n+1])
 				chWrite <- buf[:n+1]
 			}
 			if err != nil {
@@ -301,9 +414,10 @@ func execTransmitter(pmConn context.Context, ws *websocket.Conn, sessionId strin
 		var n int
 		for {
 			buf := make([]byte, 1025)
-			buf[0] = '2'
+			buf[0] = proto.stderr
 			n, err = stdErrReader.Read(buf[1:])
 			if n > 0 {
+				rec.Output(buf[1 : n+1])
 				chWrite <- buf[:n+1]
 			}
 			if err != nil {
@@ -0,0 +1,170 @@
+package container
+
+import (
+	"containerup/login"
+	"containerup/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strconv"
+)
+
+const (
+	k8sChannelProtocol = "v4.channel.k8s.io"
+
+	k8sStdin  = 0
+	k8sStdout = 1
+	k8sStderr = 2
+	k8sError  = 3
+	k8sResize = 4
+)
+
+var (
+	// exec and attach accept the Kubernetes subprotocol, the native one is used if not requested
+	execUpgrader = websocket.Upgrader{Subprotocols: []string{k8sChannelProtocol}}
+
+	nativeExecProtocol = &execProtocol{
+		stdin:  '1',
+		stdout: '1',
+		stderr: '2',
+		resize: 'r',
+		parseResize: func(data []byte) (int, int, error) {
+			if len(data) != 4 {
+				return 0, 0, errMalformedData
+			}
+			w := int(data[0])*256 + int(data[1])
+			h := int(data[2])*256 + int(data[3])
+			return w, h, nil
+		},
+		finish: finishNativeExec,
+	}
+
+	k8sExecProtocol = &execProtocol{
+		stdin:  k8sStdin,
+		stdout: k8sStdout,
+		stderr: k8sStderr,
+		resize: k8sResize,
+		parseResize: func(data []byte) (int, int, error) {
+			var size struct {
+				Width  int
+				Height int
+			}
+			if err := json.Unmarshal(data, &size); err != nil {
+				return 0, 0, errMalformedData
+			}
+			return size.Width, size.Height, nil
+		},
+		finish: finishK8sExec,
+	}
+)
+
+// execProtocol frames the streams of exec on the websocket. Each frame is prefixed with the byte of its stream.
+type execProtocol struct {
+	stdin       byte
+	stdout      byte
+	stderr      byte
+	resize      byte
+	parseResize func(data []byte) (int, int, error)
+	// finish reports the result, then closes the websocket
+	finish func(ws *websocket.Conn, err error, exitCode int)
+}
+
+// upgradeExec upgrades the request to a websocket with the negotiated protocol, and authenticates it.
+// It returns nil if failed, with the error replied.
+func upgradeExec(w http.ResponseWriter, req *http.Request) (*websocket.Conn, *execProtocol) {
+	k8s := false
+	for _, p := range websocket.Subprotocols(req) {
+		if p == k8sChannelProtocol {
+			k8s = true
+		}
+	}
+	// clients of Kubernetes cannot send the key first, so they are authenticated with the headers
+	if k8s && !login.HeaderAuth(req) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return nil, nil
+	}
+
+	ws, err := execUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// err replied in upgrader.Upgrade
+		return nil, nil
+	}
+
+	proto := execProtocolOf(ws)
+	if proto == nativeExecProtocol && !login.WebsocketAuth(ws, req.Context()) {
+		return nil, nil
+	}
+	return ws, proto
+}
+
+// execProtocolOf returns the protocol negotiated on the websocket.
+func execProtocolOf(ws *websocket.Conn) *execProtocol {
+	if ws.Subprotocol() == k8sChannelProtocol {
+		return k8sExecProtocol
+	}
+	return nativeExecProtocol
+}
+
+// fail reports an error before the streams are started.
+func (p *execProtocol) fail(ws *websocket.Conn, err error) {
+	if p == nativeExecProtocol {
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4002, err.Error()))
+		return
+	}
+	p.finish(ws, err, -1)
+}
+
+func finishNativeExec(ws *websocket.Conn, err error, exitCode int) {
+	wsCode := websocket.CloseNormalClosure
+	text := fmt.Sprintf("ExitCode %d", exitCode)
+	if err != nil {
+		wsCode = websocket.CloseInternalServerErr
+		text = err.Error()
+	}
+	err = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, text))
+	if err != nil && utils.IsWsCloseMsgTooLong(err) {
+		// error msg is too long to be sent in closeMsg
+		//ws.WriteMessage(websocket.TextMessage, []byte("e"+text)) // not yet implemented in the front-end
+		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, ""))
+	}
+}
+
+// k8sStatus is the subset of metav1.Status sent on the error channel.
+type k8sStatus struct {
+	Metadata struct{}          `json:"metadata"`
+	Status   string            `json:"status"`
+	Message  string            `json:"message,omitempty"`
+	Reason   string            `json:"reason,omitempty"`
+	Details  *k8sStatusDetails `json:"details,omitempty"`
+}
+
+type k8sStatusDetails struct {
+	Causes []k8sStatusCause `json:"causes"`
+}
+
+type k8sStatusCause struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func finishK8sExec(ws *websocket.Conn, err error, exitCode int) {
+	status := &k8sStatus{Status: "Success"}
+	switch {
+	case err != nil:
+		status.Status = "Failure"
+		status.Message = err.Error()
+		status.Reason = "InternalError"
+	case exitCode != 0:
+		status.Status = "Failure"
+		status.Message = fmt.Sprintf("command terminated with non-zero exit code: %d", exitCode)
+		status.Reason = "NonZeroExitCode"
+		status.Details = &k8sStatusDetails{
+			Causes: []k8sStatusCause{{Reason: "ExitCode", Message: strconv.Itoa(exitCode)}},
+		}
+	}
+
+	b, _ := json.Marshal(status)
+	ws.WriteMessage(websocket.BinaryMessage, append([]byte{k8sError}, b...))
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
@@ -0,0 +1,213 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/login"
+	"containerup/recording"
+	"containerup/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/api/handlers"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/docker/docker/api/types"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExecTimeout = 60
+	maxExecTimeout     = 600
+	// stdout and stderr are captured up to the size each
+	maxExecOutput = 1024 * 1024
+)
+
+type execRunReq struct {
+	Cmd     []string `json:"cmd"`
+	Env     []string `json:"env"` // KEY=VALUE
+	User    string   `json:"user"`
+	Workdir string   `json:"workdir"`
+	Timeout int      `json:"timeout"` // in seconds
+}
+
+type execRunResp struct {
+	ExitCode        int    `json:"exitCode"` // -1 if timed out
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdoutTruncated"`
+	StderrTruncated bool   `json:"stderrTruncated"`
+	// the command may still be running in the container, as Podman cannot kill an exec session
+	TimedOut bool `json:"timedOut"`
+}
+
+// RunExec runs a non-interactive command in the container, and replies its output and exit code.
+func RunExec(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	var r execRunReq
+	defer req.Body.Close()
+	err := json.NewDecoder(req.Body).Decode(&r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(r.Cmd) == 0 || r.Cmd[0] == "" {
+		http.Error(w, "command is not specified", http.StatusBadRequest)
+		return
+	}
+	for _, e := range r.Env {
+		if k, _, _ := strings.Cut(e, "="); k == "" {
+			http.Error(w, fmt.Sprintf("invalid env: %s", e), http.StatusBadRequest)
+			return
+		}
+	}
+	if r.Timeout == 0 {
+		r.Timeout = defaultExecTimeout
+	}
+	if r.Timeout < 0 || r.Timeout > maxExecTimeout {
+		http.Error(w, fmt.Sprintf("timeout should be between 1 and %d seconds", maxExecTimeout), http.StatusBadRequest)
+		return
+	}
+
+	var rec *recording.Recorder
+	if recording.Enabled(req) {
+		rec, err = recording.Start(recording.Tags{
+			User:      login.Username(),
+			Container: nameOrId,
+			Remote:    req.RemoteAddr,
+		}, r.Cmd)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Cannot record the session: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ret, err := execRun(pmConn, nameOrId, &r, rec)
+	exitCode := -1
+	if err == nil {
+		exitCode = ret.ExitCode
+	}
+	rec.Close(exitCode)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		if utils.IsErr409(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, ret)
+}
+
+// execRun runs the command, with the output recorded if rec is not nil.
+func execRun(ctx context.Context, nameOrId string, r *execRunReq, rec *recording.Recorder) (*execRunResp, error) {
+	sessionId, err := adapter.ContainerExecCreate(ctx, nameOrId, &handlers.ExecCreateConfig{ExecConfig: types.ExecConfig{
+		User:         r.User,
+		Env:          r.Env,
+		WorkingDir:   r.Workdir,
+		Cmd:          r.Cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	stdOutReader, stdOutWriter := io.Pipe()
+	stdErrReader, stdErrWriter := io.Pipe()
+	stdOut := &cappedBuffer{max: maxExecOutput}
+	stdErr := &cappedBuffer{max: maxExecOutput}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(io.MultiWriter(stdOut, recordingWriter{rec}), stdOutReader)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(io.MultiWriter(stdErr, recordingWriter{rec}), stdErrReader)
+	}()
+
+	opts := &containers.ExecStartAndAttachOptions{}
+	opts.WithAttachOutput(true)
+	opts.WithOutputStream(stdOutWriter)
+	opts.WithAttachError(true)
+	opts.WithErrorStream(stdErrWriter)
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(r.Timeout)*time.Second)
+	err = adapter.ContainerExecStartAndAttach(runCtx, sessionId, opts)
+	timedOut := errors.Is(runCtx.Err(), context.DeadlineExceeded)
+	cancel()
+	stdOutWriter.Close()
+	stdErrWriter.Close()
+	wg.Wait()
+
+	ret := &execRunResp{
+		ExitCode:        -1,
+		Stdout:          stdOut.String(),
+		Stderr:          stdErr.String(),
+		StdoutTruncated: stdOut.truncated,
+		StderrTruncated: stdErr.truncated,
+		TimedOut:        timedOut,
+	}
+	if timedOut {
+		log.Printf("Exec in container %s timed out: %v", nameOrId, r.Cmd)
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := adapter.ContainerExecInspect(ctx, sessionId, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot inspect exec session: %v", err)
+	}
+	ret.ExitCode = inspect.ExitCode
+	return ret, nil
+}
+
+// recordingWriter records the output written, see recording.Recorder.
+type recordingWriter struct {
+	rec *recording.Recorder
+}
+
+func (r recordingWriter) Write(b []byte) (int, error) {
+	r.rec.Output(b)
+	return len(b), nil
+}
+
+// cappedBuffer keeps the first max bytes written, and discards the rest without failing,
+// so that the command is not blocked on its output.
+type cappedBuffer struct {
+	strings.Builder
+	max       int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(b []byte) (int, error) {
+	n := len(b)
+	if room := c.max - c.Len(); room < len(b) {
+		c.truncated = true
+		if room <= 0 {
+			return n, nil
+		}
+		b = b[:room]
+	}
+	c.Builder.Write(b)
+	return n, nil
+}
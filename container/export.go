@@ -0,0 +1,41 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/transfer"
+	"containerup/utils"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func Export(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	t := transfer.Start(req, "container-export", data.Name, -1)
+	w.Header().Set("X-Transfer-Id", t.Id())
+	dw := transfer.NewDownloadWriter(w, "application/x-tar", data.Name+".tar")
+
+	err = adapter.ContainerExport(pmConn, data.ID, t.Writer(dw), nil)
+	t.Finish(err)
+	if err != nil {
+		if !dw.Started() {
+			http.Error(w, fmt.Sprintf("Cannot export container %s: %v", nameOrId, err), http.StatusInternalServerError)
+			return
+		}
+		utils.AbortResponse("Export container %s: %v", nameOrId, err)
+	}
+}
@@ -0,0 +1,473 @@
+package container
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/copy"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxListEntries  = 5000
+	maxListScanSize = 256 * 1024 * 1024
+	maxDownloadSize = 4 * 1024 * 1024 * 1024
+	maxUploadSize   = 4 * 1024 * 1024 * 1024
+
+	listExecTimeout     = 10
+	listStatConcurrency = 8
+	// prints the names in the directory $1 separated by NUL, with the builtins of any POSIX shell
+	listDirScript = `cd -- "$1" || exit 1; for f in * .[!.]* ..?*; do if [ -e "$f" ] || [ -L "$f" ]; then printf '%s\0' "$f"; fi; done`
+)
+
+var (
+	errSizeLimitExceeded = errors.New("size limit exceeded")
+)
+
+type fileEntry struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	ModTime    time.Time `json:"modTime"`
+	IsDir      bool      `json:"isDir"`
+	LinkTarget string    `json:"linkTarget"`
+}
+
+type filesResp struct {
+	Path      string       `json:"path"`
+	IsDir     bool         `json:"isDir"`
+	Entries   []*fileEntry `json:"entries"`
+	Truncated bool         `json:"truncated"`
+}
+
+func ListFiles(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	p := filesPath(req)
+
+	stat, ok := statPath(w, pmConn, nameOrId, p)
+	if !ok {
+		return
+	}
+
+	ret := &filesResp{
+		Path:    p,
+		IsDir:   stat.IsDir,
+		Entries: []*fileEntry{},
+	}
+	if !stat.IsDir {
+		ret.Entries = append(ret.Entries, &fileEntry{
+			Name:       stat.Name,
+			Size:       stat.Size,
+			Mode:       stat.Mode.String(),
+			ModTime:    stat.ModTime,
+			IsDir:      stat.IsDir,
+			LinkTarget: stat.LinkTarget,
+		})
+		utils.Return(w, ret)
+		return
+	}
+
+	if entries, truncated, err := listDirByStat(pmConn, nameOrId, p); err == nil {
+		ret.Entries, ret.Truncated = entries, truncated
+		utils.Return(w, ret)
+		return
+	}
+
+	// the container is not running or has no shell, so the direct children are taken from the archive of the tree
+	ctx, cancel := context.WithCancel(pmConn)
+	defer cancel()
+
+	archive, closeArchive, err := openArchive(ctx, nameOrId, p)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot read directory %s: %v", p, err), http.StatusInternalServerError)
+		return
+	}
+	defer closeArchive()
+
+	counter := &countingReader{r: archive}
+	tr := tar.NewReader(counter)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Cannot read directory %s: %v", p, err), http.StatusInternalServerError)
+			return
+		}
+
+		_, rel, _ := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+		if rel == "" || strings.Contains(rel, "/") {
+			if counter.n > maxListScanSize {
+				ret.Truncated = true
+				break
+			}
+			continue
+		}
+
+		ret.Entries = append(ret.Entries, fileEntryFromTar(rel, hdr))
+		if len(ret.Entries) >= maxListEntries || counter.n > maxListScanSize {
+			ret.Truncated = true
+			break
+		}
+	}
+
+	utils.Return(w, ret)
+}
+
+func DownloadFiles(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	p := filesPath(req)
+
+	stat, ok := statPath(w, pmConn, nameOrId, p)
+	if !ok {
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "raw"
+		if stat.IsDir {
+			format = "tar"
+		}
+	}
+	switch format {
+	case "raw":
+		if stat.IsDir {
+			http.Error(w, "A directory can only be downloaded as tar or zip", http.StatusBadRequest)
+			return
+		}
+	case "tar", "zip":
+	default:
+		http.Error(w, "unrecognized format", http.StatusBadRequest)
+		return
+	}
+	if !stat.IsDir && stat.Size > maxDownloadSize {
+		http.Error(w, fmt.Sprintf("File is larger than %d bytes", int64(maxDownloadSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(pmConn)
+	defer cancel()
+
+	archive, closeArchive, err := openArchive(ctx, nameOrId, p)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot read %s: %v", p, err), http.StatusInternalServerError)
+		return
+	}
+	defer closeArchive()
+
+	filename := stat.Name
+	if format != "raw" {
+		filename += "." + format
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	limited := &limitedWriter{w: w, remaining: maxDownloadSize}
+	switch format {
+	case "raw":
+		tr := tar.NewReader(archive)
+		hdr, err := tr.Next()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Cannot read %s: %v", p, err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(hdr.Size, 10))
+		_, err = io.Copy(limited, tr)
+	case "tar":
+		w.Header().Set("Content-Type", "application/x-tar")
+		_, err = io.Copy(limited, archive)
+	case "zip":
+		w.Header().Set("Content-Type", "application/zip")
+		err = tarToZip(limited, tar.NewReader(archive))
+	}
+
+	if err != nil {
+		utils.AbortResponse("Download %s from container %s: %v", p, nameOrId, err)
+	}
+}
+
+func UploadFiles(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	p := filesPath(req)
+	name := req.URL.Query().Get("name")
+
+	if name != "" && (name == "." || name == ".." || strings.Contains(name, "/")) {
+		http.Error(w, "Invalid file name", http.StatusBadRequest)
+		return
+	}
+	if req.ContentLength > maxUploadSize {
+		http.Error(w, fmt.Sprintf("Upload is larger than %d bytes", int64(maxUploadSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if name != "" && req.ContentLength < 0 {
+		http.Error(w, "Content-Length is required", http.StatusLengthRequired)
+		return
+	}
+
+	stat, ok := statPath(w, pmConn, nameOrId, p)
+	if !ok {
+		return
+	}
+	if !stat.IsDir {
+		http.Error(w, fmt.Sprintf("%s is not a directory", p), http.StatusBadRequest)
+		return
+	}
+
+	defer req.Body.Close()
+	body := http.MaxBytesReader(w, req.Body, maxUploadSize)
+
+	// a single file is wrapped into a tar stream, otherwise the body is a tar archive
+	var reader io.Reader = body
+	if name != "" {
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		go func() {
+			tw := tar.NewWriter(pw)
+			err := tw.WriteHeader(&tar.Header{
+				Name:    name,
+				Mode:    0644,
+				Size:    req.ContentLength,
+				ModTime: time.Now(),
+			})
+			if err == nil {
+				_, err = io.Copy(tw, body)
+			}
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		reader = pr
+	}
+
+	copyOpts := (&containers.CopyOptions{}).WithChown(true).WithNoOverwriteDirNonDir(true)
+	copyFunc, err := adapter.ContainerCopyFromArchive(pmConn, nameOrId, p, reader, copyOpts)
+	if err == nil {
+		err = copyFunc()
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Upload is larger than %d bytes", int64(maxUploadSize)), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Cannot upload to %s: %v", p, err), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, true)
+}
+
+func filesPath(req *http.Request) string {
+	p := req.URL.Query().Get("path")
+	if p == "" {
+		return "/"
+	}
+	return path.Clean("/" + p)
+}
+
+// statPath stats the path in the container, and writes the error if any.
+func statPath(w http.ResponseWriter, ctx context.Context, nameOrId, p string) (*entities.ContainerStatReport, bool) {
+	stat, err := adapter.ContainerStat(ctx, nameOrId, p)
+	if err != nil {
+		if errors.Is(err, copy.ErrENOENT) || utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find %s in container %s", p, nameOrId), http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if stat == nil {
+		http.Error(w, fmt.Sprintf("Cannot stat %s in container %s", p, nameOrId), http.StatusInternalServerError)
+		return nil, false
+	}
+	return stat, true
+}
+
+// openArchive streams the path in the container as a tar archive. Cancel the context to stop the transfer.
+func openArchive(ctx context.Context, nameOrId, p string) (io.Reader, func(), error) {
+	pr, pw := io.Pipe()
+	copyFunc, err := adapter.ContainerCopyToArchive(ctx, nameOrId, p, pw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(copyFunc())
+	}()
+
+	closeArchive := func() {
+		pr.Close()
+		<-done
+	}
+	return pr, closeArchive, nil
+}
+
+// listDirByStat lists the direct children of the directory without reading the contents of the tree,
+// by listing the names with a shell in the container, and stating each of them.
+func listDirByStat(ctx context.Context, nameOrId, p string) ([]*fileEntry, bool, error) {
+	out, err := execRun(ctx, nameOrId, &execRunReq{
+		Cmd:     []string{"/bin/sh", "-c", listDirScript, "sh", p},
+		User:    "0",
+		Timeout: listExecTimeout,
+	}, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if out.ExitCode != 0 {
+		return nil, false, fmt.Errorf("cannot list %s: %s", p, strings.TrimSpace(out.Stderr))
+	}
+
+	names := strings.Split(out.Stdout, "\x00")
+	// the last one is empty, or cut if the output is truncated
+	names = names[:len(names)-1]
+	truncated := out.StdoutTruncated
+	if len(names) > maxListEntries {
+		names = names[:maxListEntries]
+		truncated = true
+	}
+
+	entries := make([]*fileEntry, len(names))
+	sem := make(chan struct{}, listStatConcurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			stat, err := adapter.ContainerStat(ctx, nameOrId, path.Join(p, name))
+			if err != nil || stat == nil {
+				// removed in the meantime
+				return
+			}
+			entries[i] = &fileEntry{
+				Name:       name,
+				Size:       stat.Size,
+				Mode:       stat.Mode.String(),
+				ModTime:    stat.ModTime,
+				IsDir:      stat.IsDir,
+				LinkTarget: stat.LinkTarget,
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	listed := make([]*fileEntry, 0, len(entries))
+	for _, e := range entries {
+		if e != nil {
+			listed = append(listed, e)
+		}
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Name < listed[j].Name })
+	return listed, truncated, nil
+}
+
+func fileEntryFromTar(name string, hdr *tar.Header) *fileEntry {
+	info := hdr.FileInfo()
+	return &fileEntry{
+		Name:       name,
+		Size:       hdr.Size,
+		Mode:       info.Mode().String(),
+		ModTime:    hdr.ModTime,
+		IsDir:      info.IsDir(),
+		LinkTarget: hdr.Linkname,
+	}
+}
+
+// tarToZip converts the tar stream into a zip stream.
+func tarToZip(w io.Writer, tr *tar.Reader) error {
+	zw := zip.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		zipHdr, err := zip.FileInfoHeader(hdr.FileInfo())
+		if err != nil {
+			return err
+		}
+		zipHdr.Name = hdr.Name
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			zipHdr.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+		case tar.TypeReg:
+			zipHdr.Method = zip.Deflate
+		case tar.TypeSymlink:
+		default:
+			// devices, fifos and hard links are skipped
+			continue
+		}
+
+		fw, err := zw.CreateHeader(zipHdr)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			_, err = io.Copy(fw, tr)
+		case tar.TypeSymlink:
+			_, err = io.WriteString(fw, hdr.Linkname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedWriter fails when more than the remaining bytes are written.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	if int64(len(b)) > l.remaining {
+		return 0, errSizeLimitExceeded
+	}
+	l.remaining -= int64(len(b))
+	return l.w.Write(b)
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+var errContainerNotRunning = errors.New("the container is not running")
+
+func HealthCheck(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	ret, err := runHealthCheck(pmConn, nameOrId)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		if utils.IsErr409(err) || errors.Is(err, errContainerNotRunning) {
+			// no health check defined, or the container is not running
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, ret)
+}
+
+// runHealthCheck runs the health check of a running container, and returns the result with the logs.
+// Podman replies the status only, so the logs are taken from the inspection.
+func runHealthCheck(ctx context.Context, nameOrId string) (*define.HealthCheckResults, error) {
+	inspect, err := adapter.ContainerInspect(ctx, nameOrId, nil)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.State == nil || !inspect.State.Running {
+		return nil, errContainerNotRunning
+	}
+
+	result, err := adapter.ContainerRunHealthCheck(ctx, inspect.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// inspected again for the logs of the check just run
+	inspect, err = adapter.ContainerInspect(ctx, inspect.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := inspect.State.Health
+	ret.Status = result.Status
+	return &ret, nil
+}
@@ -0,0 +1,121 @@
+package container
+
+import (
+	"compress/gzip"
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/transfer"
+	"containerup/utils"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+)
+
+// LogsDownload replies the logs as an attachment, in text or NDJSON with format=text|ndjson.
+// Besides the options of Logs except follow, stdout=0 or stderr=0 leaves out the stream,
+// and gzip=1 compresses the response.
+func LogsDownload(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+
+	logOptions, processor, err := parseLogQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logOptions.WithFollow(false)
+	logOptions.WithStdout(query.Get("stdout") != "0")
+	logOptions.WithStderr(query.Get("stderr") != "0")
+	if !logOptions.GetStdout() && !logOptions.GetStderr() {
+		http.Error(w, "Either stdout or stderr should be selected", http.StatusBadRequest)
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	ext := ".log"
+	processor.raw = true
+	switch query.Get("format") {
+	case "", "text":
+		processor.structured = false
+	case "ndjson":
+		processor.structured = true
+		contentType = "application/x-ndjson"
+		ext = ".ndjson"
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+	processor.timestamps = processor.structured || query.Get("timestamps") == "1"
+	logOptions.WithTimestamps(processor.timestamps)
+
+	data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	gz := query.Get("gzip") == "1"
+	if gz {
+		contentType = "application/gzip"
+		ext += ".gz"
+	}
+	dw := transfer.NewDownloadWriter(w, contentType, data.Name+ext)
+
+	var out io.Writer = dw
+	var gzw *gzip.Writer
+	if gz {
+		gzw = gzip.NewWriter(dw)
+		out = gzw
+	}
+
+	// lines are written as they come, a failed write only drains the rest
+	var writeErr error
+	send := func(msg string) {
+		if writeErr != nil {
+			return
+		}
+		_, writeErr = io.WriteString(out, msg)
+		if writeErr == nil && processor.structured {
+			_, writeErr = io.WriteString(out, "\n")
+		}
+	}
+
+	chLines := make(chan *logLine)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for l := range chLines {
+			processor.process(l, send)
+		}
+	}()
+
+	err = containerLogs(pmConn, data.ID, "", logParserOf(data.Config.Labels), logOptions, chLines)
+	close(chLines)
+	<-done
+
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil && gzw != nil {
+		err = gzw.Close()
+	}
+	if err != nil {
+		if !dw.Started() {
+			http.Error(w, fmt.Sprintf("Cannot get logs of container %s: %v", nameOrId, err), http.StatusInternalServerError)
+			return
+		}
+		utils.AbortResponse("Download logs of container %s: %v", nameOrId, err)
+	}
+
+	if !dw.Started() {
+		// no logs at all, the attachment is still replied
+		_, _ = dw.Write(nil)
+	}
+}
@@ -0,0 +1,227 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxLogContext = 100
+
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+)
+
+var (
+	errInvalidLogContext = fmt.Errorf("context should be between 0 and %d", maxLogContext)
+	errInvalidLogLevel   = fmt.Errorf("level should be one of %s", strings.Join(logLevels, ", "))
+)
+
+// logLine is a line received from Podman. The container is only set for aggregated logs.
+type logLine struct {
+	container string
+	parser    string
+	stream    string
+	msg       string
+}
+
+type logFrame struct {
+	Container string `json:"container,omitempty"`
+	Stream    string `json:"stream"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Line      string `json:"line"`
+	// counts every line received, including the ones filtered out, so the client can tell the gaps
+	Sequence uint64 `json:"sequence"`
+	// set for the lines around a match, if context lines are requested
+	Context bool `json:"context,omitempty"`
+
+	// parsed from the line if parse=1
+	Level   string         `json:"level,omitempty"`
+	Message string         `json:"message,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// logProcessor formats the log lines, and drops the ones not matching the filter.
+// It is not safe for concurrent use.
+type logProcessor struct {
+	structured bool
+	raw        bool // lines are not prefixed with the stream in text mode
+	timestamps bool // lines are prefixed with timestamps by Podman
+	filter     *regexp.Regexp
+	context    int
+	parse      bool
+	minLevel   int // lines with known levels below it are dropped
+
+	seq    uint64
+	before []*logFrame // lines kept for the context of the next match
+	after  int         // remaining lines to be sent as the context of the last match
+}
+
+// parseLogQuery parses the log options and the processor from the query:
+//   - follow=1, tail=N, since=T, until=T and timestamps=1 are passed to Podman;
+//   - structured=1 sends JSON frames instead of lines prefixed with `1`/`2`, timestamps are always included;
+//   - filter=S keeps the lines containing S, or matching the regex S with regex=1,
+//     with context=N lines before and after each match;
+//   - parse=1 parses the lines into level, message and fields, with the parser set by the container label;
+//   - level=L drops the lines parsed with a level below L.
+func parseLogQuery(query url.Values) (*containers.LogOptions, *logProcessor, error) {
+	yes := true
+	opts := &containers.LogOptions{
+		Stdout: &yes,
+		Stderr: &yes,
+	}
+	if query.Get("follow") == "1" {
+		opts.WithFollow(true)
+	}
+	if t := query.Get("tail"); t != "" {
+		opts.WithTail(t)
+	}
+	if s := query.Get("since"); s != "" {
+		opts.WithSince(s)
+	}
+	if u := query.Get("until"); u != "" {
+		opts.WithUntil(u)
+	}
+
+	p := &logProcessor{
+		structured: query.Get("structured") == "1",
+		timestamps: query.Get("timestamps") == "1",
+	}
+	if query.Get("parse") == "1" {
+		p.parse = true
+		p.structured = true
+	}
+	if p.structured {
+		p.timestamps = true
+	}
+	if p.timestamps {
+		opts.WithTimestamps(true)
+	}
+
+	if f := query.Get("filter"); f != "" {
+		if query.Get("regex") != "1" {
+			f = regexp.QuoteMeta(f)
+		}
+		re, err := regexp.Compile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.filter = re
+	}
+	if l := query.Get("level"); l != "" {
+		rank := logLevelRank(normalizeLogLevel(l))
+		if rank < 0 {
+			return nil, nil, errInvalidLogLevel
+		}
+		p.minLevel = rank
+		p.parse = true
+	}
+	if c := query.Get("context"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 || n > maxLogContext {
+			return nil, nil, errInvalidLogContext
+		}
+		p.context = n
+	}
+
+	return opts, p, nil
+}
+
+// process handles a line received from Podman, and sends the formatted messages with send.
+func (p *logProcessor) process(l *logLine, send func(string)) {
+	msg := l.msg
+	p.seq++
+	frame := &logFrame{
+		Container: l.container,
+		Stream:    l.stream,
+		Line:      msg,
+		Sequence:  p.seq,
+	}
+	if p.timestamps {
+		frame.Timestamp, frame.Line = splitLogTimestamp(msg)
+	}
+	if p.structured {
+		frame.Line = strings.TrimSuffix(frame.Line, "\n")
+	}
+	if p.parse {
+		if parsed := parseLog(l.parser, strings.TrimSuffix(frame.Line, "\n")); parsed != nil {
+			if rank := logLevelRank(parsed.level); rank >= 0 && rank < p.minLevel {
+				return
+			}
+			if p.structured {
+				frame.Level = parsed.level
+				frame.Message = parsed.message
+				frame.Fields = parsed.fields
+			}
+		}
+	}
+
+	if p.filter == nil {
+		send(p.format(frame, msg))
+		return
+	}
+
+	if !p.filter.MatchString(frame.Line) {
+		if p.after > 0 {
+			p.after--
+			frame.Context = true
+			send(p.format(frame, msg))
+			return
+		}
+		if p.context > 0 {
+			frame.Context = true
+			if len(p.before) == p.context {
+				p.before = p.before[1:]
+			}
+			// the raw message is kept in Line for text mode
+			if !p.structured {
+				frame.Line = msg
+			}
+			p.before = append(p.before, frame)
+		}
+		return
+	}
+
+	for _, f := range p.before {
+		send(p.format(f, f.Line))
+	}
+	p.before = p.before[:0]
+	p.after = p.context
+	send(p.format(frame, msg))
+}
+
+func (p *logProcessor) format(frame *logFrame, msg string) string {
+	if !p.structured {
+		if p.raw {
+			return msg
+		}
+		if frame.Stream == streamStderr {
+			return "2" + msg
+		}
+		return "1" + msg
+	}
+	b, err := json.Marshal(frame)
+	if err != nil {
+		// never happens with strings and numbers
+		return ""
+	}
+	return string(b)
+}
+
+// splitLogTimestamp splits the RFC3339 timestamp prefixed by Podman from the line.
+func splitLogTimestamp(msg string) (string, string) {
+	ts, line, found := strings.Cut(msg, " ")
+	if !found {
+		return "", msg
+	}
+	if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		return "", msg
+	}
+	return ts, line
+}
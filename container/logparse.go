@@ -0,0 +1,228 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the label selecting the parser of the container logs: auto (default), json, logfmt or none
+	logParserLabel = "containerup.logs.parser"
+
+	logParserAuto   = "auto"
+	logParserJson   = "json"
+	logParserLogfmt = "logfmt"
+	logParserNone   = "none"
+)
+
+// canonical levels in ascending severity
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+var (
+	logLevelAliases = map[string]string{
+		"trace":     "trace",
+		"debug":     "debug",
+		"dbg":       "debug",
+		"info":      "info",
+		"notice":    "info",
+		"warn":      "warn",
+		"warning":   "warn",
+		"error":     "error",
+		"err":       "error",
+		"fatal":     "fatal",
+		"critical":  "fatal",
+		"crit":      "fatal",
+		"panic":     "fatal",
+		"emerg":     "fatal",
+		"emergency": "fatal",
+		"alert":     "fatal",
+	}
+
+	logLevelKeys   = []string{"level", "lvl", "severity", "log.level", "loglevel"}
+	logMessageKeys = []string{"msg", "message"}
+
+	// optional leading date and time, then a level like `[INFO]`, `INFO:` or `info`
+	logLevelPrefix = regexp.MustCompile(`(?i)^(?:[\d\-:.T+Z/,]+\s+){0,2}[\[(]?(trace|debug|dbg|info|notice|warn|warning|error|err|fatal|critical|crit|panic)[\])]?:?\s+(.*)$`)
+)
+
+type parsedLog struct {
+	level   string
+	message string
+	fields  map[string]any
+}
+
+// logLevelRank returns the index of the level in logLevels, or -1 if unknown.
+func logLevelRank(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// logParserOf returns the parser configured with the label, auto if not configured or invalid.
+func logParserOf(labels map[string]string) string {
+	switch p := labels[logParserLabel]; p {
+	case logParserJson, logParserLogfmt, logParserNone:
+		return p
+	default:
+		return logParserAuto
+	}
+}
+
+// parseLog parses the line with the parser, and returns nil if it is not recognized.
+func parseLog(parser string, line string) *parsedLog {
+	switch parser {
+	case logParserNone:
+		return nil
+	case logParserJson:
+		return parseJsonLog(line)
+	case logParserLogfmt:
+		return parseLogfmtLog(line)
+	}
+
+	if p := parseJsonLog(line); p != nil {
+		return p
+	}
+	if p := parseLogfmtLog(line); p != nil {
+		return p
+	}
+	return parseLevelPrefix(line)
+}
+
+func parseJsonLog(line string) *parsedLog {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil
+	}
+
+	p := &parsedLog{fields: fields}
+	for _, k := range logLevelKeys {
+		if v, ok := fields[k]; ok {
+			switch v := v.(type) {
+			case string:
+				p.level = normalizeLogLevel(v)
+			case float64:
+				// numeric levels of bunyan and pino
+				p.level = numericLogLevel(v)
+			}
+			delete(fields, k)
+			break
+		}
+	}
+	for _, k := range logMessageKeys {
+		if v, ok := fields[k]; ok {
+			p.message = fmt.Sprint(v)
+			delete(fields, k)
+			break
+		}
+	}
+	return p
+}
+
+// parseLogfmtLog parses lines like `level=info msg="hello world" took=3ms`.
+// Lines are only taken as logfmt if all the tokens are pairs, and there are at least two of them.
+func parseLogfmtLog(line string) *parsedLog {
+	fields := map[string]any{}
+	s := strings.TrimSpace(line)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.ContainsAny(s[:eq], " \t\"") {
+			return nil
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil
+			}
+			v, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil
+			}
+			value = v
+			s = s[end+1:]
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		if s != "" && s[0] != ' ' && s[0] != '\t' {
+			return nil
+		}
+		s = strings.TrimLeft(s, " \t")
+		fields[key] = value
+	}
+	if len(fields) < 2 {
+		return nil
+	}
+
+	p := &parsedLog{fields: fields}
+	for _, k := range logLevelKeys {
+		if v, ok := fields[k]; ok {
+			p.level = normalizeLogLevel(v.(string))
+			delete(fields, k)
+			break
+		}
+	}
+	for _, k := range logMessageKeys {
+		if v, ok := fields[k]; ok {
+			p.message = v.(string)
+			delete(fields, k)
+			break
+		}
+	}
+	return p
+}
+
+func parseLevelPrefix(line string) *parsedLog {
+	m := logLevelPrefix.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return nil
+	}
+	return &parsedLog{
+		level:   normalizeLogLevel(m[1]),
+		message: m[2],
+	}
+}
+
+func normalizeLogLevel(level string) string {
+	return logLevelAliases[strings.ToLower(strings.TrimSpace(level))]
+}
+
+func numericLogLevel(v float64) string {
+	switch {
+	case v >= 60:
+		return "fatal"
+	case v >= 50:
+		return "error"
+	case v >= 40:
+		return "warn"
+	case v >= 30:
+		return "info"
+	case v >= 20:
+		return "debug"
+	default:
+		return "trace"
+	}
+}
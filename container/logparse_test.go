@@ -0,0 +1,156 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser string
+		line   string
+		want   *parsedLog // nil if not recognized
+	}{
+		{
+			name:   "JSON",
+			parser: logParserAuto,
+			line:   `{"level":"WARNING","msg":"disk low","free":12}`,
+			want:   &parsedLog{level: "warn", message: "disk low", fields: map[string]any{"free": 12.0}},
+		},
+		{
+			name:   "JSON with numeric level",
+			parser: logParserAuto,
+			line:   `  {"level":30,"message":"listening","port":"80"}`,
+			want:   &parsedLog{level: "info", message: "listening", fields: map[string]any{"port": "80"}},
+		},
+		{
+			name:   "JSON with unknown level",
+			parser: logParserJson,
+			line:   `{"severity":"verbose","msg":"x"}`,
+			want:   &parsedLog{message: "x", fields: map[string]any{}},
+		},
+		{
+			name:   "malformed JSON",
+			parser: logParserAuto,
+			line:   `{"level":"info",`,
+		},
+		{
+			name:   "JSON forced on logfmt",
+			parser: logParserJson,
+			line:   `level=info msg=hello`,
+		},
+		{
+			name:   "logfmt",
+			parser: logParserAuto,
+			line:   `level=info msg="hello world" took=3ms`,
+			want:   &parsedLog{level: "info", message: "hello world", fields: map[string]any{"took": "3ms"}},
+		},
+		{
+			name:   "logfmt with escaped quotes",
+			parser: logParserLogfmt,
+			line:   `lvl=err msg="say \"hi\"" code=7`,
+			want:   &parsedLog{level: "error", message: `say "hi"`, fields: map[string]any{"code": "7"}},
+		},
+		{
+			name:   "logfmt with unterminated quote",
+			parser: logParserLogfmt,
+			line:   `level=info msg="oops`,
+		},
+		{
+			name:   "logfmt with a single pair",
+			parser: logParserLogfmt,
+			line:   `key=value`,
+		},
+		{
+			name:   "logfmt with a bare token",
+			parser: logParserLogfmt,
+			line:   `level=info hello`,
+		},
+		{
+			name:   "logfmt forced on JSON",
+			parser: logParserLogfmt,
+			line:   `{"level":"info","msg":"x"}`,
+		},
+		{
+			name:   "bracketed level after timestamp",
+			parser: logParserAuto,
+			line:   `2024-01-02T03:04:05Z [ERROR] failed to connect`,
+			want:   &parsedLog{level: "error", message: "failed to connect"},
+		},
+		{
+			name:   "level with colon after date and time",
+			parser: logParserAuto,
+			line:   `2024/01/02 03:04:05 WARN: low memory`,
+			want:   &parsedLog{level: "warn", message: "low memory"},
+		},
+		{
+			name:   "lowercase level",
+			parser: logParserAuto,
+			line:   `critical something broke`,
+			want:   &parsedLog{level: "fatal", message: "something broke"},
+		},
+		{
+			name:   "level prefix not used when forced",
+			parser: logParserLogfmt,
+			line:   `[INFO] started`,
+		},
+		{
+			name:   "plain text",
+			parser: logParserAuto,
+			line:   `hello world`,
+		},
+		{
+			name:   "disabled",
+			parser: logParserNone,
+			line:   `{"level":"info","msg":"x"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLog(tt.parser, tt.line)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogParserOf(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{nil, logParserAuto},
+		{map[string]string{logParserLabel: "json"}, logParserJson},
+		{map[string]string{logParserLabel: "logfmt"}, logParserLogfmt},
+		{map[string]string{logParserLabel: "none"}, logParserNone},
+		{map[string]string{logParserLabel: "yaml"}, logParserAuto},
+	}
+
+	for _, tt := range tests {
+		if got := logParserOf(tt.labels); got != tt.want {
+			t.Errorf("logParserOf(%v) = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestLogLevelRank(t *testing.T) {
+	tests := []struct {
+		level string
+		want  int
+	}{
+		{"trace", 0},
+		{"info", 2},
+		{"fatal", 5},
+		{"warning", -1}, // only canonical levels are ranked
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		if got := logLevelRank(tt.level); got != tt.want {
+			t.Errorf("logLevelRank(%q) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"containerup/login"
 	"containerup/utils"
 	"context"
+	"fmt"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -24,17 +25,24 @@ func Logs(w http.ResponseWriter, req *http.Request) {
 	nameOrId := vars["name"]
 	pmConn := conn.GetConn(req.Context())
 
-	yes := true
-	logOptions := &containers.LogOptions{
-		Stdout: &yes,
-		Stderr: &yes,
-	}
-	if req.URL.Query().Get("follow") == "1" {
-		t := true
-		logOptions.Follow = &t
+	logOptions, processor, err := parseLogQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if t := req.URL.Query().Get("tail"); t != "" {
-		logOptions.Tail = &t
+
+	parser := logParserAuto
+	if processor.parse {
+		data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+		if err != nil {
+			if utils.IsErr404(err) {
+				http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		parser = logParserOf(data.Config.Labels)
 	}
 
 	ws, err := upgrader.Upgrade(w, req, nil)
@@ -50,22 +58,21 @@ func Logs(w http.ResponseWriter, req *http.Request) {
 	//log.Printf("logs start...")
 	//defer log.Printf("logs end")
 
-	pmConn, stopByServer, waitEnd, chStdOut, chStdErr := logsSender(pmConn, ws)
+	pmConn, stopByServer, waitEnd, chLines := logsSender(pmConn, ws, processor)
 
-	err = adapter.ContainerLogs(pmConn, nameOrId, logOptions, chStdOut, chStdErr)
+	err = containerLogs(pmConn, nameOrId, "", parser, logOptions, chLines)
 	stopByServer(err)
 
 	waitEnd()
 }
 
-func logsSender(pmConn context.Context, ws *websocket.Conn) (context.Context, func(error), func(), chan string, chan string) {
+func logsSender(pmConn context.Context, ws *websocket.Conn, processor *logProcessor) (context.Context, func(error), func(), chan<- *logLine) {
 	pmConn, cancel := context.WithCancel(pmConn)
 
 	var wgWsReader, wgWsWriter, wgOutputReader sync.WaitGroup
 
 	chWrite := make(chan string)
-	chStdOut := make(chan string)
-	chStdErr := make(chan string)
+	chLines := make(chan *logLine)
 
 	waitEnd := func() {
 		wgOutputReader.Wait() // redundant
@@ -75,8 +82,7 @@ func logsSender(pmConn context.Context, ws *websocket.Conn) (context.Context, fu
 	}
 
 	stopByServer := func(err error) {
-		close(chStdOut)
-		close(chStdErr)
+		close(chLines)
 		wgOutputReader.Wait()
 		close(chWrite)
 		wgWsWriter.Wait()
@@ -151,23 +157,54 @@ func logsSender(pmConn context.Context, ws *websocket.Conn) (context.Context, fu
 		stopByClient(err)
 	}()
 
+	send := func(msg string) {
+		chWrite <- msg
+	}
+
 	wgOutputReader.Add(1)
 	go func() {
 		defer wgOutputReader.Done()
 
-		for msg := range chStdOut {
-			chWrite <- "1" + msg
+		for l := range chLines {
+			processor.process(l, send)
 		}
 	}()
 
-	wgOutputReader.Add(1)
+	return pmConn, stopByServer, waitEnd, chLines
+}
+
+// containerLogs gets the logs of the container, and sends the lines tagged with the container name and parser to ch.
+func containerLogs(ctx context.Context, nameOrId, container, parser string, opts *containers.LogOptions, ch chan<- *logLine) error {
+	chStdOut := make(chan string)
+	chStdErr := make(chan string)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		defer wgOutputReader.Done()
+		defer wg.Done()
 
-		for msg := range chStdErr {
-			chWrite <- "2" + msg
+		stdOut, stdErr := chStdOut, chStdErr
+		for stdOut != nil || stdErr != nil {
+			select {
+			case msg, ok := <-stdOut:
+				if !ok {
+					stdOut = nil
+					continue
+				}
+				ch <- &logLine{container: container, parser: parser, stream: streamStdout, msg: msg}
+			case msg, ok := <-stdErr:
+				if !ok {
+					stdErr = nil
+					continue
+				}
+				ch <- &logLine{container: container, parser: parser, stream: streamStderr, msg: msg}
+			}
 		}
 	}()
 
-	return pmConn, stopByServer, waitEnd, chStdOut, chStdErr
+	err := adapter.ContainerLogs(ctx, nameOrId, opts, chStdOut, chStdErr)
+	close(chStdOut)
+	close(chStdErr)
+	wg.Wait()
+	return err
 }
@@ -0,0 +1,332 @@
+package container
+
+import (
+	"container/heap"
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/login"
+	"context"
+	"errors"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// lines are held for a while to be merged in timestamp order with the lines of other containers
+	logsMergeWindow = 500 * time.Millisecond
+	// how often to look for containers started later and matching the selector
+	logsScanInterval = 5 * time.Second
+)
+
+var (
+	errNoLogsSelector = errors.New("no container, label or pod is specified")
+)
+
+// logsSelector selects the containers by names or IDs, labels and pod.
+type logsSelector struct {
+	names  []string
+	labels []string // key=value or key
+	pod    string
+}
+
+// AggregatedLogs follows the logs of multiple containers, merged in timestamp order and sent as structured frames.
+// The containers are selected with container=NAME (repeatable), label=KEY=VALUE (repeatable) or pod=NAME,
+// the other options are the same as Logs.
+func AggregatedLogs(w http.ResponseWriter, req *http.Request) {
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+
+	sel := &logsSelector{
+		names:  query["container"],
+		labels: query["label"],
+		pod:    query.Get("pod"),
+	}
+	if len(sel.names) == 0 && len(sel.labels) == 0 && sel.pod == "" {
+		http.Error(w, errNoLogsSelector.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logOptions, processor, err := parseLogQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// timestamps are needed for the merge
+	processor.structured = true
+	processor.timestamps = true
+	logOptions.WithTimestamps(true)
+
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// err replied in upgrader.Upgrade
+		return
+	}
+
+	if !login.WebsocketAuth(ws, req.Context()) {
+		return
+	}
+
+	pmConn, stopByServer, waitEnd, chLines := logsSender(pmConn, ws, processor)
+
+	err = aggregateLogs(pmConn, sel, logOptions, chLines)
+	stopByServer(err)
+
+	waitEnd()
+}
+
+// aggregateLogs gets the logs of the selected containers. If following, it runs until ctx is done,
+// and picks up the containers started later.
+func aggregateLogs(ctx context.Context, sel *logsSelector, opts *containers.LogOptions, out chan<- *logLine) error {
+	begin := time.Now()
+	follow := opts.GetFollow()
+
+	list, err := sel.list(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	chMerge := make(chan *logLine)
+	mergeDone := make(chan struct{})
+	go func() {
+		defer close(mergeDone)
+		mergeLogs(ctx, chMerge, out)
+	}()
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var mutex sync.Mutex
+	following := map[string]bool{}
+	lastEnd := map[string]time.Time{}
+
+	startFollowing := func(c entities.ListContainer, opts *containers.LogOptions) {
+		following[c.ID] = true
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := containerLogs(ctx, c.ID, name, logParserOf(c.Labels), opts, chMerge)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			delete(following, c.ID)
+			lastEnd[c.ID] = time.Now()
+			if err != nil && ctx.Err() == nil {
+				if follow {
+					// the container may be removed, the others are still followed
+					log.Printf("Aggregated logs of container %s: %v", name, err)
+				} else if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}()
+	}
+
+	mutex.Lock()
+	for _, c := range list {
+		startFollowing(c, opts)
+	}
+	mutex.Unlock()
+
+	if follow {
+		ticker := time.NewTicker(logsScanInterval)
+	scan:
+		for {
+			select {
+			case <-ctx.Done():
+				break scan
+			case <-ticker.C:
+			}
+
+			list, err := sel.list(ctx, false)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Aggregated logs: %v", err)
+				}
+				continue
+			}
+
+			mutex.Lock()
+			for _, c := range list {
+				if following[c.ID] {
+					continue
+				}
+				// only the logs since it was last seen, or since the beginning if it is new
+				since, ok := lastEnd[c.ID]
+				if !ok {
+					since = begin
+				}
+				lateOpts := (&containers.LogOptions{}).
+					WithStdout(true).
+					WithStderr(true).
+					WithFollow(true).
+					WithTimestamps(true).
+					WithSince(since.Format(time.RFC3339Nano))
+				if opts.Until != nil {
+					lateOpts.WithUntil(*opts.Until)
+				}
+				startFollowing(c, lateOpts)
+			}
+			mutex.Unlock()
+		}
+		ticker.Stop()
+	}
+
+	wg.Wait()
+	close(chMerge)
+	<-mergeDone
+
+	if ctx.Err() != nil {
+		// stopped by the client
+		return nil
+	}
+	return firstErr
+}
+
+// list lists the selected containers, only the running ones unless all is set.
+func (s *logsSelector) list(ctx context.Context, all bool) ([]entities.ListContainer, error) {
+	opts := (&containers.ListOptions{}).WithAll(all)
+	filters := map[string][]string{}
+	if len(s.labels) > 0 {
+		filters["label"] = s.labels
+	}
+	if s.pod != "" {
+		filters["pod"] = []string{s.pod}
+	}
+	if len(filters) > 0 {
+		opts.WithFilters(filters)
+	}
+
+	list, err := adapter.ContainerList(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.names) == 0 {
+		return list, nil
+	}
+
+	// the name filter of Podman matches with regex, so the names are matched exactly here
+	var ret []entities.ListContainer
+	for _, c := range list {
+		if s.matchName(c) {
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+func (s *logsSelector) matchName(c entities.ListContainer) bool {
+	for _, n := range s.names {
+		if len(n) >= 12 && strings.HasPrefix(c.ID, n) {
+			return true
+		}
+		for _, name := range c.Names {
+			if name == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type pendingLogLine struct {
+	line      *logLine
+	timestamp time.Time
+	arrived   time.Time
+	seq       uint64 // keeps the order of the lines with the same timestamp
+}
+
+type logLineHeap []*pendingLogLine
+
+func (h logLineHeap) Len() int {
+	return len(h)
+}
+
+func (h logLineHeap) Less(i, j int) bool {
+	if h[i].timestamp.Equal(h[j].timestamp) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].timestamp.Before(h[j].timestamp)
+}
+
+func (h logLineHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *logLineHeap) Push(x any) {
+	*h = append(*h, x.(*pendingLogLine))
+}
+
+func (h *logLineHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// mergeLogs sends the lines from in to out in timestamp order, as far as they arrive within logsMergeWindow.
+// It returns when in is closed. The lines are discarded once ctx is done, so the senders are never blocked.
+func mergeLogs(ctx context.Context, in <-chan *logLine, out chan<- *logLine) {
+	h := &logLineHeap{}
+	var seq uint64
+
+	ticker := time.NewTicker(logsMergeWindow / 5)
+	defer ticker.Stop()
+
+	flush := func(all bool) {
+		now := time.Now()
+		for h.Len() > 0 {
+			p := (*h)[0]
+			if !all && now.Sub(p.arrived) < logsMergeWindow {
+				return
+			}
+			heap.Pop(h)
+			select {
+			case out <- p.line:
+			case <-ctx.Done():
+				*h = (*h)[:0]
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case l, ok := <-in:
+			if !ok {
+				flush(true)
+				return
+			}
+			if ctx.Err() != nil {
+				continue
+			}
+			now := time.Now()
+			seq++
+			p := &pendingLogLine{
+				line:      l,
+				timestamp: now,
+				arrived:   now,
+				seq:       seq,
+			}
+			if ts, _ := splitLogTimestamp(l.msg); ts != "" {
+				if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+					p.timestamp = t
+				}
+			}
+			heap.Push(h, p)
+		case <-ticker.C:
+			flush(false)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/specgen"
+	"log"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultStopTimeout = 10
+	defaultPidsLimit   = 2048
+	defaultShmSize     = 64 * 1024 * 1024
+
+	verifyInterval        = 2 * time.Second
+	verifyAttempts        = 10
+	verifyRunningAttempts = 3
+
+	// the rollback is done even if the request has ended
+	rollbackTimeout = 2 * time.Minute
+)
+
+var (
+	shellSafeRegex = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+	// annotations added by Podman itself
+	internalAnnotationPrefixes = []string{
+		"io.container.manager",
+		"io.kubernetes.cri-o.",
+		"io.podman.annotations.",
+		"org.opencontainers.image.stopSignal",
+	}
+)
+
+// createReqFromInspect rebuilds the creation request of an existing container.
+// Values inherited from the image are omitted, so that they follow the image when the container is recreated.
+func createReqFromInspect(ctx context.Context, data *define.InspectContainerData) (*createReq, error) {
+	img, err := adapter.ImageGet(ctx, data.Image, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot inspect image %s: %v", data.ImageName, err)
+	}
+	imgConfig := img.Config
+	if imgConfig == nil {
+		return nil, fmt.Errorf("image %s has no config", data.ImageName)
+	}
+	if data.Config == nil || data.HostConfig == nil {
+		return nil, fmt.Errorf("container %s has no config", data.Name)
+	}
+	if data.IsInfra {
+		return nil, fmt.Errorf("container %s is the infra container of a pod", data.Name)
+	}
+	config := data.Config
+	hostConfig := data.HostConfig
+	// settings which cannot be kept, reported instead of dropped
+	var unsupported []string
+
+	c := &createReq{
+		Name:  data.Name,
+		Image: data.ImageName,
+		Start: data.State != nil && data.State.Running,
+	}
+
+	if entrypoint := strings.Join(imgConfig.Entrypoint, " "); config.Entrypoint != entrypoint {
+		c.Entrypoint = &config.Entrypoint
+		// the command of the image is dropped along with the entrypoint
+		cmd := shellJoin(config.Cmd)
+		c.Command = &cmd
+	} else if !reflect.DeepEqual(config.Cmd, imgConfig.Cmd) && len(config.Cmd) > 0 {
+		cmd := shellJoin(config.Cmd)
+		c.Command = &cmd
+	}
+
+	workDir := imgConfig.WorkingDir
+	if workDir == "" {
+		workDir = "/"
+	}
+	if config.WorkingDir != "" && config.WorkingDir != workDir {
+		c.WorkDir = &config.WorkingDir
+	}
+	if config.User != imgConfig.User && config.User != "" {
+		c.User = &config.User
+	}
+	if config.Hostname != "" && !strings.HasPrefix(data.ID, config.Hostname) {
+		c.Hostname = &config.Hostname
+	}
+
+	imgEnv := map[string]bool{}
+	for _, e := range imgConfig.Env {
+		imgEnv[e] = true
+	}
+	for _, e := range config.Env {
+		k, v, _ := strings.Cut(e, "=")
+		if imgEnv[e] || k == "container" || k == "HOSTNAME" {
+			continue
+		}
+		if c.Env == nil {
+			c.Env = map[string]string{}
+		}
+		c.Env[k] = v
+	}
+
+	for k, v := range config.Labels {
+		if imgV, ok := imgConfig.Labels[k]; ok && imgV == v {
+			continue
+		}
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[k] = v
+	}
+
+	for k, v := range config.Annotations {
+		internal := false
+		for _, prefix := range internalAnnotationPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				internal = true
+				break
+			}
+		}
+		if internal {
+			continue
+		}
+		if c.Annotations == nil {
+			c.Annotations = map[string]string{}
+		}
+		c.Annotations[k] = v
+	}
+
+	for _, m := range data.Mounts {
+		rw := "rw"
+		if !m.RW {
+			rw = "ro"
+		}
+		switch m.Type {
+		case "bind":
+			c.Volumes = append(c.Volumes, &volumeReq{
+				Type:      "bind",
+				Container: m.Destination,
+				Host:      m.Source,
+				ReadWrite: rw,
+			})
+		case "volume":
+			c.Volumes = append(c.Volumes, &volumeReq{
+				Type:      "volume",
+				Container: m.Destination,
+				Host:      m.Name,
+				ReadWrite: rw,
+			})
+		case define.TypeTmpfs:
+			if _, ok := hostConfig.Tmpfs[m.Destination]; !ok {
+				unsupported = append(unsupported, fmt.Sprintf("tmpfs mount %s", m.Destination))
+			}
+		default:
+			unsupported = append(unsupported, fmt.Sprintf("%s mount %s", m.Type, m.Destination))
+		}
+	}
+
+	for _, dest := range sortedKeys(hostConfig.Tmpfs) {
+		c.Tmpfs = append(c.Tmpfs, &tmpfsReq{
+			Container: dest,
+			Options:   hostConfig.Tmpfs[dest],
+		})
+	}
+
+	portKeys := make([]string, 0, len(hostConfig.PortBindings))
+	for k := range hostConfig.PortBindings {
+		portKeys = append(portKeys, k)
+	}
+	sort.Strings(portKeys)
+	for _, k := range portKeys {
+		portStr, protocol, _ := strings.Cut(k, "/")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		ctnPort, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s: %v", k, err)
+		}
+
+		p := &portReq{
+			Container: uint16(ctnPort),
+			Protocol:  protocol,
+		}
+		for _, hp := range hostConfig.PortBindings[k] {
+			hostPort := uint64(0)
+			if hp.HostPort != "" {
+				hostPort, err = strconv.ParseUint(hp.HostPort, 10, 16)
+				if err != nil {
+					return nil, fmt.Errorf("invalid host port %s: %v", hp.HostPort, err)
+				}
+			}
+			p.Host = append(p.Host, &portHost{Addr: hp.HostIP, Port: uint16(hostPort)})
+		}
+		c.Ports = append(c.Ports, p)
+	}
+
+	res := &resReq{}
+	if hostConfig.CpuShares > 0 {
+		res.CpuShares = int(hostConfig.CpuShares)
+	}
+	if hostConfig.CpuQuota > 0 && hostConfig.CpuPeriod > 0 {
+		res.CpuCores = float64(hostConfig.CpuQuota) / float64(hostConfig.CpuPeriod)
+	} else if hostConfig.NanoCpus > 0 {
+		res.CpuCores = float64(hostConfig.NanoCpus) / 1e9
+	}
+	if hostConfig.Memory > 0 {
+		res.MemoryMB = int(hostConfig.Memory / 1024 / 1024)
+		// the swap limit defaults to double of the memory limit
+		if hostConfig.MemorySwap > 0 && hostConfig.MemorySwap != hostConfig.Memory*2 {
+			res.MemoryWithSwapMB = int(hostConfig.MemorySwap / 1024 / 1024)
+		}
+	}
+	if hostConfig.PidsLimit != 0 && hostConfig.PidsLimit != defaultPidsLimit {
+		res.PidsLimit = hostConfig.PidsLimit
+	}
+	if *res != (resReq{}) {
+		c.Resources = res
+	}
+
+	for _, u := range hostConfig.Ulimits {
+		name := strings.ToLower(strings.TrimPrefix(u.Name, "RLIMIT_"))
+		c.Ulimits = append(c.Ulimits, fmt.Sprintf("%s=%d:%d", name, u.Soft, u.Hard))
+	}
+
+	c.CapAdd = hostConfig.CapAdd
+	c.CapDrop = hostConfig.CapDrop
+
+	for _, d := range hostConfig.Devices {
+		dev := d.PathOnHost
+		if d.PathInContainer != "" {
+			dev += ":" + d.PathInContainer
+			if d.CgroupPermissions != "" {
+				dev += ":" + d.CgroupPermissions
+			}
+		}
+		c.Devices = append(c.Devices, dev)
+	}
+
+	c.DnsServers = hostConfig.Dns
+	c.DnsSearch = hostConfig.DnsSearch
+	c.ExtraHosts = hostConfig.ExtraHosts
+
+	if config.StopSignal != 0 && syscall.Signal(config.StopSignal) != syscall.SIGTERM {
+		stopSignal := strconv.FormatUint(uint64(config.StopSignal), 10)
+		c.StopSignal = &stopSignal
+	}
+	if config.StopTimeout != defaultStopTimeout {
+		stopTimeout := config.StopTimeout
+		c.StopTimeout = &stopTimeout
+	}
+	c.Init = hostConfig.Init
+	c.ReadOnly = hostConfig.ReadonlyRootfs
+
+	if lc := hostConfig.LogConfig; lc != nil && lc.Type != "" {
+		// the log path is generated by Podman by default, so it is not kept
+		l := &logReq{
+			Driver:  lc.Type,
+			Options: map[string]string{},
+		}
+		for k, v := range lc.Config {
+			l.Options[k] = v
+		}
+		if lc.Tag != "" {
+			l.Options["tag"] = lc.Tag
+		}
+		if lc.Size != "" && lc.Size != "0B" && lc.Size != "-1" {
+			l.Options["max-size"] = lc.Size
+		}
+		c.Log = l
+	}
+
+	if hc := config.Healthcheck; hc != nil && !reflect.DeepEqual(hc, img.HealthCheck) && len(hc.Test) > 0 {
+		h := &healthCheckReq{
+			Interval:    hc.Interval.String(),
+			Timeout:     hc.Timeout.String(),
+			Retries:     uint(hc.Retries),
+			StartPeriod: hc.StartPeriod.String(),
+			OnFailure:   config.HealthcheckOnFailureAction,
+		}
+		switch hc.Test[0] {
+		case define.HealthConfigTestNone:
+			h.Command = "none"
+		case define.HealthConfigTestCmdShell:
+			h.Command = strings.Join(hc.Test[1:], " ")
+		default:
+			cmdJson, _ := json.Marshal(hc.Test[1:])
+			h.Command = string(cmdJson)
+		}
+		c.HealthCheck = h
+	}
+
+	if data.Pod != "" {
+		c.Pod = data.Pod
+	}
+	// the network namespace is shared by the infra container of the pod
+	if mode := hostConfig.NetworkMode; mode != "" && !(data.Pod != "" && strings.HasPrefix(mode, "container:")) {
+		n := &networkReq{Mode: mode}
+		if mode == string(specgen.Bridge) && data.NetworkSettings != nil {
+			for name := range data.NetworkSettings.Networks {
+				n.Networks = append(n.Networks, name)
+			}
+			sort.Strings(n.Networks)
+		}
+		c.Network = n
+	}
+	c.Privileged = hostConfig.Privileged
+	c.SecurityOpts = hostConfig.SecurityOpt
+	if hostConfig.ShmSize > 0 && hostConfig.ShmSize != defaultShmSize {
+		if hostConfig.ShmSize%(1024*1024) != 0 {
+			unsupported = append(unsupported, fmt.Sprintf("shm size of %d bytes", hostConfig.ShmSize))
+		}
+		c.ShmSizeMB = int(hostConfig.ShmSize / 1024 / 1024)
+	}
+
+	if rp := hostConfig.RestartPolicy; rp != nil && rp.Name != define.RestartPolicyNone && rp.Name != define.RestartPolicyNo {
+		c.RestartPolicy = &restartPolicyReq{
+			Policy:     rp.Name,
+			MaxRetries: rp.MaximumRetryCount,
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("cannot keep the settings of container %s: %s", data.Name, strings.Join(unsupported, ", "))
+	}
+	return c, nil
+}
+
+type recreateOpts struct {
+	progress func(format string, args ...any) // reports the steps, optional
+	verify   bool                             // checks the new container is running and healthy after started
+}
+
+// recreate replaces the container with a new one created by the request.
+// The current container is renamed with suffix `-old` and removed after the new one is created and started,
+// or restored if anything goes wrong.
+func recreate(ctx context.Context, current *define.InspectContainerData, c *createReq, opts *recreateOpts) (newId string, err error) {
+	if opts == nil {
+		opts = &recreateOpts{}
+	}
+	progress := func(format string, args ...any) {
+		log.Printf("Recreate container %s: "+format, append([]any{current.Name}, args...)...)
+		if opts.progress != nil {
+			opts.progress(format, args...)
+		}
+	}
+
+	s, err := buildSpec(c)
+	if err != nil {
+		return "", err
+	}
+
+	success := false
+
+	if current.State != nil && current.State.Running {
+		progress("stopping the current container")
+		err = stop(ctx, current.ID)
+		if err != nil {
+			return "", fmt.Errorf("cannot stop the current container: %v", err)
+		}
+		defer func() {
+			if !success {
+				ctx, cancel := rollbackContext(ctx)
+				defer cancel()
+				err := start(ctx, current.ID)
+				progress("restart the current container, result err: %v", err)
+			}
+		}()
+	}
+
+	progress("renaming the current container to %s", current.Name+"-old")
+	err = adapter.ContainerRename(ctx, current.ID, (&containers.RenameOptions{}).WithName(current.Name+"-old"))
+	if err != nil {
+		return "", fmt.Errorf("cannot rename the current container: %v", err)
+	}
+	defer func() {
+		if !success {
+			ctx, cancel := rollbackContext(ctx)
+			defer cancel()
+			err := adapter.ContainerRename(ctx, current.ID, (&containers.RenameOptions{}).WithName(current.Name))
+			progress("revert rename of the current container, result err: %v", err)
+		}
+	}()
+
+	progress("creating the new container")
+	rpt, err := adapter.ContainerCreateWithSpec(ctx, s, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot create a new container: %v", err)
+	}
+	defer func() {
+		if !success {
+			ctx, cancel := rollbackContext(ctx)
+			defer cancel()
+			_, err := adapter.ContainerRemove(ctx, rpt.ID, (&containers.RemoveOptions{}).WithForce(true).WithTimeout(10))
+			progress("remove the new container, result err: %v", err)
+		}
+	}()
+
+	if c.Start {
+		progress("starting the new container")
+		err = start(ctx, rpt.ID)
+		if err != nil {
+			return "", fmt.Errorf("cannot start the new container: %v", err)
+		}
+
+		if opts.verify {
+			err = verifyStarted(ctx, rpt.ID, progress)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	success = true
+
+	progress("removing the old container")
+	err = remove(ctx, current.ID)
+	if err != nil {
+		progress("cannot remove the old container: %v", err)
+	}
+
+	return rpt.ID, nil
+}
+
+// rollbackContext returns the context to roll back with, not cancelled with the request of ctx.
+func rollbackContext(ctx context.Context) (context.Context, func()) {
+	return context.WithTimeout(conn.GetDetachedConn(ctx), rollbackTimeout)
+}
+
+// verifyStarted waits for the started container to keep running for a while,
+// and to become healthy if it has a health check.
+func verifyStarted(ctx context.Context, id string, progress func(format string, args ...any)) error {
+	for i := 0; i < verifyAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(verifyInterval):
+		}
+
+		progress("checking the status of the new container")
+		data, err := adapter.ContainerInspect(ctx, id, nil)
+		if err != nil {
+			return fmt.Errorf("cannot inspect the new container: %v", err)
+		}
+		if data.State == nil || !data.State.Running {
+			exitCode := int32(0)
+			if data.State != nil {
+				exitCode = data.State.ExitCode
+			}
+			return fmt.Errorf("the new container exited with code %d", exitCode)
+		}
+
+		hc := data.Config.Healthcheck
+		if hc == nil || len(hc.Test) == 0 || hc.Test[0] == define.HealthConfigTestNone {
+			if i+1 >= verifyRunningAttempts {
+				return nil
+			}
+			continue
+		}
+
+		result, err := runHealthCheck(ctx, id)
+		if err != nil {
+			progress("health check failed: %v", err)
+			continue
+		}
+		if result.Status == define.HealthCheckHealthy {
+			return nil
+		}
+		progress("health status: %s", result.Status)
+	}
+	return errors.New("the new container is not healthy")
+}
+
+// shellJoin quotes the arguments to be parsed by shellwords.
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, a := range args {
+		if shellSafeRegex.MatchString(a) {
+			quoted = append(quoted, a)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(a, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
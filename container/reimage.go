@@ -0,0 +1,204 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/login"
+	"containerup/utils"
+	"context"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
+	"github.com/containers/podman/v4/pkg/bindings/images"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func Reimage(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+	imgName := query.Get("image")
+	force := query.Get("force") == "1"
+
+	data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// err replied in upgrader.Upgrade
+		return
+	}
+
+	if !login.WebsocketAuth(ws, req.Context()) {
+		return
+	}
+
+	progressReader, progressWriter := io.Pipe()
+	sendStep, stopByServer, waitEnd := reimageTransmitter(ws, progressReader)
+
+	// the recreation is not cancelled when the client goes away, to make sure rollbacks are done
+	newId, err := reimage(pmConn, data, imgName, force, progressWriter, sendStep)
+	stopByServer(newId, err)
+	waitEnd()
+}
+
+// reimage pulls the image and recreates the container on it, keeping the configuration of the container.
+// Unless forced, the container is kept if it is already on the pulled image.
+func reimage(ctx context.Context, current *define.InspectContainerData, imgName string, force bool, progressWriter *io.PipeWriter, sendStep func(format string, args ...any)) (string, error) {
+	if imgName == "" {
+		imgName = current.ImageName
+	}
+
+	sendStep("pulling image %s", imgName)
+	imgs, err := adapter.ImagePull(ctx, imgName, (&images.PullOptions{}).WithProgressWriter(progressWriter))
+	progressWriter.Close()
+	if err != nil {
+		return "", fmt.Errorf("cannot pull image %s: %v", imgName, err)
+	}
+	if len(imgs) > 0 && imgs[0] == current.Image && imgName == current.ImageName && !force {
+		sendStep("the container is already on the latest image")
+		return current.ID, nil
+	}
+
+	c, err := createReqFromInspect(ctx, current)
+	if err != nil {
+		return "", err
+	}
+	c.Image = imgName
+
+	return recreate(ctx, current, c, &recreateOpts{
+		progress: sendStep,
+		verify:   true,
+	})
+}
+
+// reimageTransmitter sends the pull progress with prefix `0`, and the steps with prefix `1`.
+// The new container ID is sent with prefix `s` at last.
+func reimageTransmitter(ws *websocket.Conn, progressReader io.ReadCloser) (func(string, ...any), func(string, error), func()) {
+	var wgWsReader, wgWsWriter, wgOutputReader sync.WaitGroup
+	var sendMutex sync.Mutex
+
+	chWrite := make(chan []byte)
+
+	sendStep := func(format string, args ...any) {
+		sendMutex.Lock()
+		defer sendMutex.Unlock()
+		chWrite <- []byte("1" + fmt.Sprintf(format, args...))
+	}
+
+	waitEnd := func() {
+		wgOutputReader.Wait() // redundant
+		wgWsWriter.Wait()     // redundant
+		wgWsReader.Wait()
+	}
+
+	stopByServer := func(newId string, err error) {
+		progressReader.Close()
+
+		wgOutputReader.Wait()
+		sendMutex.Lock()
+		close(chWrite)
+		sendMutex.Unlock()
+		wgWsWriter.Wait()
+
+		if err == nil {
+			ws.WriteMessage(websocket.TextMessage, []byte("s"+newId))
+		}
+
+		wsCode := websocket.CloseNormalClosure
+		text := ""
+		if err != nil {
+			wsCode = 4000
+			text = err.Error()
+		}
+		err = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, text))
+		if err != nil && utils.IsWsCloseMsgTooLong(err) {
+			// error msg is too long to be sent in closeMsg
+			ws.WriteMessage(websocket.TextMessage, []byte("e"+text))
+			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, ""))
+		}
+	}
+
+	ws.SetCloseHandler(func(code int, text string) error {
+		_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+		return nil
+	})
+
+	// empty reader
+	wgWsReader.Add(1)
+	go func() {
+		defer wgWsReader.Done()
+
+		var err error
+		for err == nil {
+			_, _, err = ws.ReadMessage()
+		}
+	}()
+
+	wgWsWriter.Add(1)
+	go func() {
+		defer wgWsWriter.Done()
+
+		// keep draining the messages even if the client has gone, so that the recreation is not blocked
+		wsOk := true
+		end := false
+		for !end {
+			select {
+			case msg, ok := <-chWrite:
+				if !ok {
+					end = true
+					break
+				}
+				if !wsOk {
+					break
+				}
+				err := ws.WriteMessage(websocket.TextMessage, msg)
+				if err != nil {
+					log.Printf("ws write err: %v", err)
+					wsOk = false
+				}
+
+			case <-time.After(20 * time.Second):
+				if wsOk && ws.WriteMessage(websocket.PingMessage, nil) != nil {
+					wsOk = false
+				}
+			}
+		}
+	}()
+
+	wgOutputReader.Add(1)
+	go func() {
+		defer wgOutputReader.Done()
+
+		var err error
+		var n int
+		for {
+			buf := make([]byte, 1025)
+			buf[0] = '0'
+			n, err = progressReader.Read(buf[1:])
+			if n > 0 {
+				sendMutex.Lock()
+				chWrite <- buf[:n+1]
+				sendMutex.Unlock()
+			}
+			if err != nil {
+				break
+			}
+		}
+	}()
+
+	return sendStep, stopByServer, waitEnd
+}
@@ -0,0 +1,215 @@
+package container
+
+import (
+	"containerup/adapter"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/containers/podman/v4/pkg/specgen"
+	"github.com/containers/podman/v4/pkg/util"
+	spec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// resourcesPatchReq changes the resource limits. Nil values are kept unchanged.
+type resourcesPatchReq struct {
+	CpuShares    *uint64  `json:"cpuShares"`
+	CpuCores     *float64 `json:"cpuCores"` // 0 for unlimited
+	CpuQuota     *int64   `json:"cpuQuota"` // -1 for unlimited
+	CpuPeriod    *uint64  `json:"cpuPeriod"`
+	MemoryMB     *int64   `json:"memoryMB"`     // 0 for unlimited
+	MemorySwapMB *int64   `json:"memorySwapMB"` // -1 for unlimited swap, double of the memory limit by default
+	PidsLimit    *int64   `json:"pidsLimit"`    // -1 for unlimited
+
+	// recreate the container if Podman cannot update it in place, which changes the ID and restarts it
+	AllowRecreate bool `json:"allowRecreate"`
+}
+
+type resourcesResp struct {
+	CpuShares    uint64  `json:"cpuShares"`
+	CpuCores     float64 `json:"cpuCores"`
+	CpuQuota     int64   `json:"cpuQuota"`
+	CpuPeriod    uint64  `json:"cpuPeriod"`
+	MemoryMB     int64   `json:"memoryMB"`
+	MemorySwapMB int64   `json:"memorySwapMB"`
+	PidsLimit    int64   `json:"pidsLimit"`
+}
+
+var (
+	errInvalidResources  = errors.New("invalid resource limits")
+	errSwapBelowMemory   = fmt.Errorf("%w: the swap limit should not be less than the memory limit", errInvalidResources)
+	errUpdateUnsupported = errors.New("the version of Podman cannot update the resource limits in place, set allowRecreate to recreate the container")
+)
+
+// updateResources changes the resource limits of a container in place.
+// On Podman versions without the update endpoint, the container is recreated if allowed.
+// The ID of the container and the effective limits are returned.
+func updateResources(ctx context.Context, nameOrId string, r *resourcesPatchReq) (string, *resourcesResp, error) {
+	if !r.valid() {
+		return "", nil, errInvalidResources
+	}
+
+	current, err := adapter.ContainerInspect(ctx, nameOrId, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if current.HostConfig == nil {
+		return "", nil, errors.New("the container has no host config")
+	}
+
+	id := current.ID
+	s := specgen.NewSpecGenerator("", false)
+	s.ResourceLimits = r.apply(current.HostConfig)
+	// checked before the container may be stopped to be recreated
+	if m := s.ResourceLimits.Memory; m.Limit != nil && *m.Limit > 0 && m.Swap != nil && *m.Swap != -1 && *m.Swap < *m.Limit {
+		return "", nil, errSwapBelowMemory
+	}
+	_, err = adapter.ContainerUpdate(ctx, &entities.ContainerUpdateOptions{
+		NameOrID: current.ID,
+		Specgen:  s,
+	})
+	if errors.Is(err, adapter.ErrUnsupported) {
+		if !r.AllowRecreate {
+			return "", nil, errUpdateUnsupported
+		}
+		c, err := createReqFromInspect(ctx, current)
+		if err != nil {
+			return "", nil, err
+		}
+		c.Resources = resReqFromLimits(s.ResourceLimits)
+		id, err = recreate(ctx, current, c, nil)
+		if err != nil {
+			return "", nil, err
+		}
+	} else if err != nil {
+		return "", nil, err
+	}
+
+	updated, err := adapter.ContainerInspect(ctx, id, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, effectiveResources(updated.HostConfig), nil
+}
+
+func (r *resourcesPatchReq) valid() bool {
+	return (r.CpuCores == nil || *r.CpuCores >= 0) &&
+		(r.CpuQuota == nil || *r.CpuQuota == -1 || *r.CpuQuota >= 1000) &&
+		(r.CpuPeriod == nil || (*r.CpuPeriod >= 1000 && *r.CpuPeriod <= 1000000)) &&
+		(r.MemoryMB == nil || *r.MemoryMB == 0 || *r.MemoryMB >= minMemoryMB) &&
+		(r.MemorySwapMB == nil || *r.MemorySwapMB == -1 || *r.MemorySwapMB > 0) &&
+		(r.PidsLimit == nil || *r.PidsLimit == -1 || *r.PidsLimit > 0) &&
+		(r.CpuShares == nil || (*r.CpuShares >= minCpuShares && *r.CpuShares <= maxCpuShares))
+}
+
+// apply merges the changes into the current limits, since Podman replaces all the limits.
+func (r *resourcesPatchReq) apply(h *define.InspectContainerHostConfig) *spec.LinuxResources {
+	res := &spec.LinuxResources{
+		CPU:    &spec.LinuxCPU{},
+		Memory: &spec.LinuxMemory{},
+	}
+
+	shares, quota, period := h.CpuShares, h.CpuQuota, h.CpuPeriod
+	if r.CpuShares != nil {
+		shares = *r.CpuShares
+	}
+	if r.CpuCores != nil {
+		if *r.CpuCores > 0 {
+			period, quota = util.CoresToPeriodAndQuota(*r.CpuCores)
+		} else {
+			quota = -1
+		}
+	}
+	if r.CpuQuota != nil {
+		quota = *r.CpuQuota
+	}
+	if r.CpuPeriod != nil {
+		period = *r.CpuPeriod
+	}
+	if shares > 0 {
+		res.CPU.Shares = &shares
+	}
+	if quota != 0 {
+		res.CPU.Quota = &quota
+	}
+	if period > 0 {
+		res.CPU.Period = &period
+	}
+
+	limit, swap := h.Memory, h.MemorySwap
+	if r.MemoryMB != nil {
+		limit = *r.MemoryMB * 1024 * 1024
+		if limit == 0 {
+			limit, swap = -1, -1
+		} else {
+			swap = limit * 2
+		}
+	}
+	if r.MemorySwapMB != nil {
+		swap = *r.MemorySwapMB
+		if swap > 0 {
+			swap *= 1024 * 1024
+		}
+	}
+	if limit != 0 {
+		res.Memory.Limit = &limit
+	}
+	if swap != 0 {
+		res.Memory.Swap = &swap
+	}
+
+	pids := h.PidsLimit
+	if r.PidsLimit != nil {
+		pids = *r.PidsLimit
+	}
+	if pids != 0 {
+		res.Pids = &spec.LinuxPids{Limit: pids}
+	}
+
+	return res
+}
+
+// resReqFromLimits converts the limits into the creation request, used when the container is recreated.
+func resReqFromLimits(res *spec.LinuxResources) *resReq {
+	ret := &resReq{}
+	if res.CPU.Shares != nil {
+		ret.CpuShares = int(*res.CPU.Shares)
+	}
+	if res.CPU.Quota != nil && *res.CPU.Quota > 0 && res.CPU.Period != nil && *res.CPU.Period > 0 {
+		ret.CpuCores = float64(*res.CPU.Quota) / float64(*res.CPU.Period)
+	}
+	if res.Memory.Limit != nil && *res.Memory.Limit > 0 {
+		ret.MemoryMB = int(*res.Memory.Limit / 1024 / 1024)
+		if res.Memory.Swap != nil && *res.Memory.Swap > 0 && *res.Memory.Swap != *res.Memory.Limit*2 {
+			ret.MemoryWithSwapMB = int(*res.Memory.Swap / 1024 / 1024)
+		}
+	}
+	if res.Pids != nil {
+		ret.PidsLimit = res.Pids.Limit
+	}
+	return ret
+}
+
+func effectiveResources(h *define.InspectContainerHostConfig) *resourcesResp {
+	ret := &resourcesResp{
+		CpuShares: h.CpuShares,
+		CpuQuota:  h.CpuQuota,
+		CpuPeriod: h.CpuPeriod,
+		PidsLimit: h.PidsLimit,
+	}
+	if h.CpuQuota > 0 && h.CpuPeriod > 0 {
+		ret.CpuCores = float64(h.CpuQuota) / float64(h.CpuPeriod)
+	} else if h.NanoCpus > 0 {
+		ret.CpuCores = float64(h.NanoCpus) / 1e9
+	}
+	if h.Memory > 0 {
+		ret.MemoryMB = h.Memory / 1024 / 1024
+	}
+	if h.MemorySwap > 0 {
+		ret.MemorySwapMB = h.MemorySwap / 1024 / 1024
+	} else if h.MemorySwap < 0 {
+		ret.MemorySwapMB = -1
+	}
+	return ret
+}
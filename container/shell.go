@@ -0,0 +1,262 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/gorilla/mux"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// a shell is probed with a short command, and regarded as missing if it does not exit in time
+	shellProbeTimeout = 5
+	maxShellProfiles  = 32
+)
+
+var (
+	// probed in order, the first found is the default profile
+	shellCandidates = []string{"/bin/bash", "/bin/zsh", "/bin/ash", "/bin/sh"}
+
+	// saved profiles by the name of the container, stored in shellProfilesPath if specified
+	savedShellProfiles = map[string][]*shellProfile{}
+	// detected profiles by the ID of the container, as the shells do not change until it is recreated
+	detectedShellProfiles = map[string][]*shellProfile{}
+	shellProfilesMutex    sync.Mutex
+	shellProfilesPath     string
+
+	errNoSuchShellProfile = errors.New("no such shell profile")
+)
+
+type shellProfile struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+	Env     []string `json:"env,omitempty"` // KEY=VALUE
+	User    string   `json:"user,omitempty"`
+	Workdir string   `json:"workdir,omitempty"`
+}
+
+type shellProfilesResp struct {
+	Profiles []*shellProfile `json:"profiles"`
+	// the profiles are detected if not saved
+	Saved bool `json:"saved"`
+}
+
+// InitShellProfiles loads the saved shell profiles from the file, and saves them to it since.
+// Without it, the profiles are kept in memory only.
+func InitShellProfiles(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &savedShellProfiles); err != nil {
+			return fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+	}
+	shellProfilesPath = path
+	return nil
+}
+
+// ShellProfiles lists the shell profiles of the container. Unless saved, they are detected by probing the container.
+func ShellProfiles(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	ret, err := shellProfiles(pmConn, nameOrId)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errContainerNotRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, ret)
+}
+
+// SaveShellProfiles saves the shell profiles of the container. An empty list falls back to detection.
+func SaveShellProfiles(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	var profiles []*shellProfile
+	defer req.Body.Close()
+	err := json.NewDecoder(req.Body).Decode(&profiles)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateShellProfiles(profiles); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := adapter.ContainerInspect(pmConn, nameOrId, nil)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := saveShellProfiles(data.Name, profiles); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, true)
+}
+
+func validateShellProfiles(profiles []*shellProfile) error {
+	if len(profiles) > maxShellProfiles {
+		return fmt.Errorf("at most %d shell profiles can be saved", maxShellProfiles)
+	}
+	names := map[string]bool{}
+	for _, p := range profiles {
+		if p == nil || p.Name == "" {
+			return errors.New("the name of a shell profile is not specified")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate shell profile: %s", p.Name)
+		}
+		names[p.Name] = true
+		if len(p.Command) == 0 || p.Command[0] == "" {
+			return fmt.Errorf("the command of shell profile %s is not specified", p.Name)
+		}
+		for _, e := range p.Env {
+			if k, _, _ := strings.Cut(e, "="); k == "" {
+				return fmt.Errorf("invalid env of shell profile %s: %s", p.Name, e)
+			}
+		}
+	}
+	return nil
+}
+
+func saveShellProfiles(container string, profiles []*shellProfile) error {
+	shellProfilesMutex.Lock()
+	defer shellProfilesMutex.Unlock()
+
+	if len(profiles) == 0 {
+		delete(savedShellProfiles, container)
+	} else {
+		savedShellProfiles[container] = profiles
+	}
+	if shellProfilesPath == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(savedShellProfiles)
+	if err != nil {
+		return err
+	}
+	// written to a temporary file first, so that the saved ones are not lost if failed
+	tmp := filepath.Join(filepath.Dir(shellProfilesPath), "."+filepath.Base(shellProfilesPath)+".tmp")
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, shellProfilesPath)
+}
+
+func shellProfiles(ctx context.Context, nameOrId string) (*shellProfilesResp, error) {
+	data, err := adapter.ContainerInspect(ctx, nameOrId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	shellProfilesMutex.Lock()
+	saved := savedShellProfiles[data.Name]
+	detected, ok := detectedShellProfiles[data.ID]
+	shellProfilesMutex.Unlock()
+
+	if len(saved) > 0 {
+		return &shellProfilesResp{Profiles: saved, Saved: true}, nil
+	}
+	if ok {
+		return &shellProfilesResp{Profiles: detected}, nil
+	}
+
+	if data.State == nil || !data.State.Running {
+		return nil, errContainerNotRunning
+	}
+	detected = detectShellProfiles(ctx, data.ID)
+	// nothing found may be a transient failure, e.g. right after started, so it is probed again next time
+	if len(detected) > 0 {
+		cacheShellProfiles(ctx, data.ID, detected)
+	}
+
+	return &shellProfilesResp{Profiles: detected}, nil
+}
+
+// cacheShellProfiles caches the detected profiles, and forgets those of the containers removed.
+func cacheShellProfiles(ctx context.Context, id string, profiles []*shellProfile) {
+	list, err := adapter.ContainerList(ctx, (&containers.ListOptions{}).WithAll(true))
+
+	shellProfilesMutex.Lock()
+	defer shellProfilesMutex.Unlock()
+
+	detectedShellProfiles[id] = profiles
+	if err != nil {
+		return
+	}
+	exists := map[string]bool{}
+	for _, c := range list {
+		exists[c.ID] = true
+	}
+	for cached := range detectedShellProfiles {
+		if cached != id && !exists[cached] {
+			delete(detectedShellProfiles, cached)
+		}
+	}
+}
+
+// detectShellProfiles probes the shells of shellCandidates in the container, by running them with a no-op.
+func detectShellProfiles(ctx context.Context, id string) []*shellProfile {
+	profiles := make([]*shellProfile, 0)
+	for _, sh := range shellCandidates {
+		ret, err := execRun(ctx, id, &execRunReq{
+			Cmd:     []string{sh, "-c", "exit 0"},
+			Timeout: shellProbeTimeout,
+		}, nil)
+		if err != nil || ret.ExitCode != 0 {
+			continue
+		}
+		profiles = append(profiles, &shellProfile{
+			Name:    filepath.Base(sh),
+			Command: []string{sh},
+		})
+	}
+	return profiles
+}
+
+// shellProfileOf returns the shell profile of the container by name, or the first one if the name is empty.
+func shellProfileOf(ctx context.Context, nameOrId, name string) (*shellProfile, error) {
+	ret, err := shellProfiles(ctx, nameOrId)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ret.Profiles {
+		if name == "" || p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, errNoSuchShellProfile
+}
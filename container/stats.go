@@ -125,6 +125,8 @@ type TotalStats struct {
 	NetworkOut uint64
 	BlockIn    uint64
 	BlockOut   uint64
+	// the stats of each container, as reported by Podman
+	Containers []define.ContainerStats
 }
 
 func TotalStatsStream(ctx context.Context, interval int) (<-chan *TotalStats, error) {
@@ -189,6 +191,7 @@ func TotalStatsStream(ctx context.Context, interval int) (<-chan *TotalStats, er
 				NetworkOut: netOut / uint64(interval),
 				BlockIn:    blkIn / uint64(interval),
 				BlockOut:   blkOut / uint64(interval),
+				Containers: report.Stats,
 			}
 		}
 	}()
@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"github.com/containers/podman/v4/libpod/define"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/containers/podman/v4/pkg/bindings/system"
 	"github.com/containers/podman/v4/pkg/domain/entities"
@@ -208,6 +209,36 @@ func SubscribeToContainer(ctx context.Context, msg *wstypes.WsReqMessage, writer
 		}
 	}
 
+	var healthMutex sync.Mutex
+	lastHealthStatus := ""
+	sendSingleAndCheck := func() error {
+		ret, err := sendSingle(ctx, msg.Index, writer, containerShortId)
+		if err != nil {
+			return err
+		}
+
+		healthMutex.Lock()
+		defer healthMutex.Unlock()
+
+		status := ""
+		if ret.State != nil {
+			status = ret.State.Health.Status
+		}
+		if lastHealthStatus == define.HealthCheckHealthy && status == define.HealthCheckUnhealthy {
+			writer <- &wstypes.WsRespMessage{
+				Index: msg.Index,
+				Data: &healthChangedEvent{
+					Event:          "unhealthy",
+					PreviousStatus: lastHealthStatus,
+					Status:         status,
+					Health:         &ret.State.Health,
+				},
+			}
+		}
+		lastHealthStatus = status
+		return nil
+	}
+
 	var wg sync.WaitGroup
 	ch := make(chan entities.Event)
 
@@ -244,12 +275,12 @@ func SubscribeToContainer(ctx context.Context, msg *wstypes.WsReqMessage, writer
 			}
 
 			switch event.Action {
-			case "create", "start", "died", "pause", "unpause", "remove", "rename":
+			case "create", "start", "died", "pause", "unpause", "remove", "rename", "health_status":
 				if graceCancel != nil {
 					graceCancel()
 					graceCancel = nil
 				}
-				err = sendSingle(ctx, msg.Index, writer, containerShortId)
+				err = sendSingleAndCheck()
 			}
 			if err != nil {
 				onError(err)
@@ -257,7 +288,7 @@ func SubscribeToContainer(ctx context.Context, msg *wstypes.WsReqMessage, writer
 		}
 	}()
 
-	err = sendSingle(ctx, msg.Index, writer, containerShortId)
+	err = sendSingleAndCheck()
 	if err != nil {
 		onError(err)
 	}
@@ -272,17 +303,26 @@ func SubscribeToContainer(ctx context.Context, msg *wstypes.WsReqMessage, writer
 	}
 }
 
-func sendSingle(ctx context.Context, index uint, writer chan<- *wstypes.WsRespMessage, id string) error {
+// healthChangedEvent is sent to the subscriber of a single container,
+// in addition to the inspection, when the container turns from healthy to unhealthy.
+type healthChangedEvent struct {
+	Event          string                     `json:"event"`
+	PreviousStatus string                     `json:"previousStatus"`
+	Status         string                     `json:"status"`
+	Health         *define.HealthCheckResults `json:"health"`
+}
+
+func sendSingle(ctx context.Context, index uint, writer chan<- *wstypes.WsRespMessage, id string) (*define.InspectContainerData, error) {
 	ret, err := adapter.ContainerInspect(ctx, id, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	writer <- &wstypes.WsRespMessage{
 		Index: index,
 		Data:  ret,
 	}
-	return nil
+	return ret, nil
 }
 
 func UnsubscribeToContainer(ctx context.Context, msg *wstypes.WsReqMessage, writer chan<- *wstypes.WsRespMessage) {
@@ -0,0 +1,415 @@
+package container
+
+import (
+	"bufio"
+	"containerup/adapter"
+	"containerup/recording"
+	"containerup/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// a terminal without clients is killed after the grace period
+	terminalGrace      = 5 * time.Minute
+	terminalScrollback = 256 * 1024
+	maxTerminals       = 32
+	// output buffered for a client, which is detached if it cannot keep up
+	terminalClientBuffer = 256
+)
+
+var (
+	terminals      = map[string]*terminal{}
+	terminalsMutex sync.Mutex
+
+	errTooManyTerminals = errors.New("too many terminal sessions")
+	errNoSuchTerminal   = errors.New("no such terminal session")
+	errTerminalWriter   = errors.New("the terminal already has a writer")
+	errTerminalEnded    = errors.New("the terminal session has ended")
+	errTerminalDetached = errors.New("detached for being too slow")
+)
+
+// execRecorder records an exec session, see recording.Recorder.
+type execRecorder interface {
+	Input(b []byte)
+	Output(b []byte)
+	Resize(width, height int)
+}
+
+// inputRecorder records the input of a writer, as the output of a terminal is recorded once by the terminal.
+type inputRecorder struct {
+	*recording.Recorder
+}
+
+func (inputRecorder) Output([]byte) {}
+
+// terminal is an exec session living server-side, so that clients can detach and reattach to it.
+type terminal struct {
+	id        string
+	sessionId string
+	container string
+	cmd       []string
+	created   time.Time
+	ctx       context.Context // the connection of Podman, cancelled to kill the session
+	cancel    func()
+	stdin     io.WriteCloser
+	rec       *recording.Recorder
+
+	mutex      sync.Mutex
+	scrollback []byte
+	clients    map[*terminalClient]struct{}
+	writer     *terminalClient
+	grace      *time.Timer
+	ended      bool
+	exitCode   int
+	err        error
+}
+
+type terminalClient struct {
+	ch chan []byte // closed when the client is detached
+}
+
+type terminalResp struct {
+	Id        string   `json:"id"`
+	Container string   `json:"container"`
+	Command   []string `json:"command"`
+	Created   int64    `json:"created"`
+	Clients   int      `json:"clients"`
+	HasWriter bool     `json:"hasWriter"`
+}
+
+// ListTerminals lists the terminal sessions, optionally of the container=NAME only.
+func ListTerminals(w http.ResponseWriter, req *http.Request) {
+	container := req.URL.Query().Get("container")
+
+	terminalsMutex.Lock()
+	ret := make([]*terminalResp, 0, len(terminals))
+	for _, t := range terminals {
+		if container != "" && t.container != container {
+			continue
+		}
+		t.mutex.Lock()
+		ret = append(ret, &terminalResp{
+			Id:        t.id,
+			Container: t.container,
+			Command:   t.cmd,
+			Created:   t.created.Unix(),
+			Clients:   len(t.clients),
+			HasWriter: t.writer != nil,
+		})
+		t.mutex.Unlock()
+	}
+	terminalsMutex.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created < ret[j].Created
+	})
+	utils.Return(w, ret)
+}
+
+// AttachTerminal reattaches to a terminal session, as a read-only viewer unless write=1.
+func AttachTerminal(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	writable := req.URL.Query().Get("write") == "1"
+
+	t := getTerminal(id)
+	if t == nil {
+		http.Error(w, errNoSuchTerminal.Error(), http.StatusNotFound)
+		return
+	}
+
+	ws, proto := upgradeExec(w, req)
+	if ws == nil {
+		return
+	}
+
+	serveTerminal(t, ws, proto, writable)
+}
+
+// KillTerminal ends a terminal session.
+func KillTerminal(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+
+	t := getTerminal(id)
+	if t == nil {
+		http.Error(w, errNoSuchTerminal.Error(), http.StatusNotFound)
+		return
+	}
+	t.cancel()
+
+	utils.Return(w, true)
+}
+
+func getTerminal(id string) *terminal {
+	terminalsMutex.Lock()
+	defer terminalsMutex.Unlock()
+	return terminals[id]
+}
+
+func terminalCount() int {
+	terminalsMutex.Lock()
+	defer terminalsMutex.Unlock()
+	return len(terminals)
+}
+
+// startTerminal starts the created exec session in the background.
+// ctx should not be cancelled with the request.
+func startTerminal(ctx context.Context, sessionId, container string, cmd []string, interactive bool, rec *recording.Recorder) (*terminal, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	t := &terminal{
+		id:        utils.RandString(16),
+		sessionId: sessionId,
+		container: container,
+		cmd:       cmd,
+		created:   time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		rec:       rec,
+		clients:   map[*terminalClient]struct{}{},
+		exitCode:  -1,
+	}
+
+	terminalsMutex.Lock()
+	if len(terminals) >= maxTerminals {
+		terminalsMutex.Unlock()
+		cancel()
+		return nil, errTooManyTerminals
+	}
+	terminals[t.id] = t
+	terminalsMutex.Unlock()
+
+	stdOutReader, stdOutWriter := io.Pipe()
+	stdErrReader, stdErrWriter := io.Pipe()
+
+	yes := true
+	startOpts := &containers.ExecStartAndAttachOptions{
+		AttachOutput: &yes,
+		AttachError:  &yes,
+	}
+	startOpts.WithOutputStream(stdOutWriter)
+	startOpts.WithErrorStream(stdErrWriter)
+	if interactive {
+		stdInReader, stdInWriter := io.Pipe()
+		t.stdin = stdInWriter
+		startOpts.AttachInput = &yes
+		startOpts.InputStream = bufio.NewReader(stdInReader)
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range []io.Reader{stdOutReader, stdErrReader} {
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+			buf := make([]byte, 1024)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					t.output(buf[:n])
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(r)
+	}
+
+	go func() {
+		err := adapter.ContainerExecStartAndAttach(ctx, sessionId, startOpts)
+		stdOutWriter.Close()
+		stdErrWriter.Close()
+		wg.Wait()
+
+		exitCode := -1
+		if ctx.Err() == nil {
+			inspectOut, err := adapter.ContainerExecInspect(ctx, sessionId, nil)
+			if err != nil {
+				log.Printf("inspect err : %v", err)
+			} else {
+				exitCode = inspectOut.ExitCode
+			}
+		}
+		cancel()
+		rec.Close(exitCode)
+		t.end(err, exitCode)
+	}()
+
+	return t, nil
+}
+
+// output appends the output to the scrollback, and sends it to the clients.
+func (t *terminal) output(b []byte) {
+	t.rec.Output(b)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.scrollback = append(t.scrollback, b...)
+	if over := len(t.scrollback) - terminalScrollback; over > 0 {
+		t.scrollback = append(t.scrollback[:0], t.scrollback[over:]...)
+	}
+
+	for c := range t.clients {
+		select {
+		case c.ch <- append([]byte(nil), b...):
+		default:
+			t.detachLocked(c)
+		}
+	}
+}
+
+func (t *terminal) end(err error, exitCode int) {
+	terminalsMutex.Lock()
+	delete(terminals, t.id)
+	terminalsMutex.Unlock()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.ended = true
+	t.err = err
+	t.exitCode = exitCode
+	if t.grace != nil {
+		t.grace.Stop()
+	}
+	for c := range t.clients {
+		t.detachLocked(c)
+	}
+}
+
+// attach adds a client, with the scrollback as the first output.
+func (t *terminal) attach(writable bool) (*terminalClient, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.ended {
+		return nil, errTerminalEnded
+	}
+	if writable && t.writer != nil {
+		return nil, errTerminalWriter
+	}
+
+	c := &terminalClient{
+		ch: make(chan []byte, terminalClientBuffer),
+	}
+	if len(t.scrollback) > 0 {
+		c.ch <- append([]byte(nil), t.scrollback...)
+	}
+	t.clients[c] = struct{}{}
+	if writable {
+		t.writer = c
+	}
+	if t.grace != nil {
+		t.grace.Stop()
+		t.grace = nil
+	}
+	return c, nil
+}
+
+func (t *terminal) detach(c *terminalClient) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.detachLocked(c)
+}
+
+func (t *terminal) detachLocked(c *terminalClient) {
+	if _, ok := t.clients[c]; !ok {
+		return
+	}
+	delete(t.clients, c)
+	if t.writer == c {
+		t.writer = nil
+	}
+	close(c.ch)
+
+	if len(t.clients) == 0 && !t.ended {
+		t.grace = time.AfterFunc(terminalGrace, t.cancel)
+	}
+}
+
+func (t *terminal) result() (bool, int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.ended, t.exitCode, t.err
+}
+
+// terminalInput writes the input of the writer to the terminal. It is never closed by the client.
+type terminalInput struct {
+	t *terminal
+}
+
+func (i *terminalInput) Write(b []byte) (int, error) {
+	return i.t.stdin.Write(b)
+}
+
+func (i *terminalInput) Close() error {
+	return nil
+}
+
+// serveTerminal transmits the terminal to the websocket, until either of them ends.
+func serveTerminal(t *terminal, ws *websocket.Conn, proto *execProtocol, writable bool) {
+	if writable && t.stdin == nil {
+		// not interactive
+		writable = false
+	}
+
+	c, err := t.attach(writable)
+	if err != nil {
+		proto.fail(ws, err)
+		return
+	}
+
+	stdOutReader, stdOutWriter := io.Pipe()
+	stdErrReader, stdErrWriter := io.Pipe()
+	// closed once the output buffered for the client is forwarded, after it is detached
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		var err error
+		for b := range c.ch {
+			if err == nil {
+				_, err = stdOutWriter.Write(b)
+			}
+		}
+		stdOutWriter.Close()
+		stdErrWriter.Close()
+	}()
+
+	var stdIn io.WriteCloser
+	var rec execRecorder = inputRecorder{}
+	if writable {
+		stdIn = &terminalInput{t: t}
+		rec = inputRecorder{t.rec}
+	}
+
+	pmConn, stopByServer, waitEnd := execTransmitter(t.ctx, ws, proto, execResizer(t.sessionId), stdOutReader, stdErrReader, stdIn, rec)
+
+	select {
+	case <-pmConn.Done():
+		// the client has left, or the terminal is killed
+	case <-drained:
+	}
+	t.detach(c)
+
+	ended, exitCode, err := t.result()
+	if !ended && err == nil && pmConn.Err() == nil {
+		err = errTerminalDetached
+	}
+	stopByServer(err, exitCode)
+
+	waitEnd()
+}
+
+// sendTerminalId sends the ID of the terminal, to reattach to it later.
+func sendTerminalId(ws *websocket.Conn, t *terminal) error {
+	return ws.WriteMessage(websocket.BinaryMessage, []byte(fmt.Sprintf("s%s", t.id)))
+}
@@ -0,0 +1,176 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/utils"
+	"containerup/wsrouter/wstypes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTopInterval = 5
+	minTopInterval     = 1
+)
+
+var (
+	subTopMap   = map[uint]func(){}
+	subTopMutex sync.Mutex
+)
+
+type topResp struct {
+	Titles    []string   `json:"titles"`
+	Processes [][]string `json:"processes"`
+}
+
+type subscribeTopReq struct {
+	Container   string   `json:"container"`
+	Descriptors []string `json:"descriptors"`
+	Interval    int      `json:"interval"` // in seconds
+}
+
+func Top(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	nameOrId := vars["name"]
+	pmConn := conn.GetConn(req.Context())
+
+	var descriptors []string
+	if d := req.URL.Query().Get("descriptors"); d != "" {
+		descriptors = strings.Split(d, ",")
+	}
+
+	ret, err := containerTop(pmConn, nameOrId, descriptors)
+	if err != nil {
+		if utils.IsErr404(err) {
+			http.Error(w, fmt.Sprintf("Cannot find container %s", nameOrId), http.StatusNotFound)
+			return
+		}
+		if utils.IsErr409(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, ret)
+}
+
+func SubscribeToContainerTop(ctx context.Context, msg *wstypes.WsReqMessage, writer chan<- *wstypes.WsRespMessage) {
+	var r subscribeTopReq
+	err := json.Unmarshal(msg.Data, &r)
+	if err != nil || r.Container == "" {
+		if err == nil {
+			err = errors.New("container is not specified")
+		}
+		writer <- &wstypes.WsRespMessage{
+			Index: msg.Index,
+			Error: true,
+			Data:  err.Error(),
+		}
+		return
+	}
+	if r.Interval == 0 {
+		r.Interval = defaultTopInterval
+	}
+	if r.Interval < minTopInterval {
+		r.Interval = minTopInterval
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	subTopMutex.Lock()
+	subTopMap[msg.Index] = cancel
+	subTopMutex.Unlock()
+	defer func() {
+		subTopMutex.Lock()
+		defer subTopMutex.Unlock()
+		delete(subTopMap, msg.Index)
+	}()
+
+	ticker := time.NewTicker(time.Duration(r.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ret, err := containerTop(ctx, r.Container, r.Descriptors)
+		if err != nil {
+			if ctx.Err() == nil {
+				writer <- &wstypes.WsRespMessage{
+					Index: msg.Index,
+					Error: true,
+					Data:  err.Error(),
+				}
+			}
+			return
+		}
+		writer <- &wstypes.WsRespMessage{
+			Index: msg.Index,
+			Data:  ret,
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func UnsubscribeToContainerTop(ctx context.Context, msg *wstypes.WsReqMessage, writer chan<- *wstypes.WsRespMessage) {
+	var unsubId uint
+	err := json.Unmarshal(msg.Data, &unsubId)
+	if err != nil {
+		writer <- &wstypes.WsRespMessage{
+			Index: msg.Index,
+			Data:  false,
+		}
+		return
+	}
+
+	subTopMutex.Lock()
+	if c, ok := subTopMap[unsubId]; ok {
+		c()
+		delete(subTopMap, unsubId)
+	}
+	subTopMutex.Unlock()
+
+	writer <- &wstypes.WsRespMessage{
+		Index: msg.Index,
+		Data:  true,
+	}
+}
+
+// containerTop lists the processes of the container, with the ps descriptors like `pid,user,pcpu,args`.
+func containerTop(ctx context.Context, nameOrId string, descriptors []string) (*topResp, error) {
+	opts := &containers.TopOptions{}
+	if len(descriptors) > 0 {
+		opts.WithDescriptors(descriptors)
+	}
+	lines, err := adapter.ContainerTop(ctx, nameOrId, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &topResp{
+		Titles:    []string{},
+		Processes: [][]string{},
+	}
+	for i, line := range lines {
+		if i == 0 {
+			ret.Titles = strings.Split(line, "\t")
+			continue
+		}
+		ret.Processes = append(ret.Processes, strings.Split(line, "\t"))
+	}
+	return ret, nil
+}
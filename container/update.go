@@ -6,6 +6,9 @@ import (
 	"containerup/utils"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/gorilla/mux"
 	"net/http"
@@ -30,6 +33,7 @@ func Patch(w http.ResponseWriter, req *http.Request) {
 
 	pmConn := conn.GetConn(req.Context())
 
+	ret := any(true)
 	switch act.Type {
 	case "rename":
 		name := ""
@@ -40,6 +44,41 @@ func Patch(w http.ResponseWriter, req *http.Request) {
 		}
 		err = rename(pmConn, nameOrId, name)
 
+	case "restart-policy":
+		var policy restartPolicyReq
+		err = json.Unmarshal(act.Data, &policy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := restartPolicy(&policy); err != nil {
+			http.Error(w, fmt.Sprintf("invalid restart policy: %v", err), http.StatusBadRequest)
+			return
+		}
+		var newId string
+		newId, err = updateRestartPolicy(pmConn, nameOrId, &policy)
+		ret = map[string]any{"Id": newId}
+
+	case "update-resources":
+		var r resourcesPatchReq
+		err = json.Unmarshal(act.Data, &r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var id string
+		var res *resourcesResp
+		id, res, err = updateResources(pmConn, nameOrId, &r)
+		if errors.Is(err, errInvalidResources) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errUpdateUnsupported) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		ret = map[string]any{"Id": id, "Resources": res}
+
 	default:
 		http.Error(w, "unrecognized patch type", http.StatusBadRequest)
 		return
@@ -54,10 +93,30 @@ func Patch(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	utils.Return(w, true)
+	utils.Return(w, ret)
 }
 
 func rename(ctx context.Context, nameOrId, name string) error {
 	opts := &containers.RenameOptions{Name: &name}
 	return adapter.ContainerRename(ctx, nameOrId, opts)
 }
+
+// updateRestartPolicy changes the restart policy of a container.
+// Podman cannot change it in place, so the container is recreated, and the ID of the new container is returned.
+func updateRestartPolicy(ctx context.Context, nameOrId string, policy *restartPolicyReq) (string, error) {
+	current, err := adapter.ContainerInspect(ctx, nameOrId, nil)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := createReqFromInspect(ctx, current)
+	if err != nil {
+		return "", err
+	}
+
+	c.RestartPolicy = policy
+	if policy.Policy == define.RestartPolicyNo {
+		c.RestartPolicy = nil
+	}
+	return recreate(ctx, current, c, nil)
+}
@@ -0,0 +1,220 @@
+package container
+
+import (
+	"containerup/adapter"
+	"containerup/utils"
+	"context"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	minMemoryMB  = 6
+	minCpuShares = 2
+	maxCpuShares = 262144
+)
+
+// dryRunAndReturn validates the creation request without creating anything, and writes the problems found,
+// the resolved spec and the equivalent podman command.
+func dryRunAndReturn(w http.ResponseWriter, ctx context.Context, c *createReq) {
+	problems := validateReq(c)
+
+	command := ""
+	s, err := buildSpec(c)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		command = shellJoin(s.ContainerCreateCommand)
+	}
+
+	envProblems, err := validateEnv(ctx, c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	problems = append(problems, envProblems...)
+
+	utils.Return(w, map[string]any{
+		"Valid":   len(problems) == 0,
+		"Errors":  problems,
+		"Spec":    s,
+		"Command": command,
+	})
+}
+
+// validateReq checks the creation request itself.
+func validateReq(c *createReq) []string {
+	problems := []string{}
+
+	if c.Image == "" {
+		problems = append(problems, "image is not specified")
+	}
+
+	for _, v := range c.Volumes {
+		if !isCleanAbsPath(v.Container) {
+			problems = append(problems, fmt.Sprintf("container path of volume %s must be an absolute path", v.Container))
+		}
+		switch v.Type {
+		case "", "bind":
+			if !isCleanAbsPath(v.Host) {
+				problems = append(problems, fmt.Sprintf("host path %s must be an absolute path", v.Host))
+			}
+		case "volume":
+			if v.Host == "" {
+				problems = append(problems, fmt.Sprintf("volume name of %s is not specified", v.Container))
+			}
+		}
+	}
+	seenMounts := map[string]bool{}
+	for _, v := range c.Volumes {
+		if seenMounts[v.Container] {
+			problems = append(problems, fmt.Sprintf("duplicate mount destination %s", v.Container))
+		}
+		seenMounts[v.Container] = true
+	}
+	for _, t := range c.Tmpfs {
+		if seenMounts[t.Container] {
+			problems = append(problems, fmt.Sprintf("duplicate mount destination %s", t.Container))
+		}
+		seenMounts[t.Container] = true
+	}
+
+	type hostPortKey struct {
+		port     uint16
+		protocol string
+	}
+	seenPorts := map[hostPortKey]string{}
+	for _, p := range c.Ports {
+		if p.Container == 0 {
+			problems = append(problems, "container port must not be 0")
+		}
+		for _, h := range p.Host {
+			if h.Port == 0 {
+				continue
+			}
+			key := hostPortKey{port: h.Port, protocol: p.Protocol}
+			if addr, ok := seenPorts[key]; ok && addrOverlaps(addr, h.Addr) {
+				problems = append(problems, fmt.Sprintf("host port %d/%s is mapped more than once", h.Port, p.Protocol))
+			}
+			seenPorts[key] = h.Addr
+		}
+	}
+
+	if res := c.Resources; res != nil {
+		if res.CpuShares < 0 || (res.CpuShares > 0 && res.CpuShares < minCpuShares) || res.CpuShares > maxCpuShares {
+			problems = append(problems, fmt.Sprintf("cpu shares must be between %d and %d", minCpuShares, maxCpuShares))
+		}
+		if res.CpuCores < 0 {
+			problems = append(problems, "cpu cores must not be negative")
+		}
+		if res.MemoryMB < 0 || (res.MemoryMB > 0 && res.MemoryMB < minMemoryMB) {
+			problems = append(problems, fmt.Sprintf("memory limit must be at least %d MB", minMemoryMB))
+		}
+		if res.PidsLimit < -1 {
+			problems = append(problems, "pids limit must be -1 for unlimited, or a positive number")
+		}
+		if res.MemoryWithSwapMB < 0 {
+			problems = append(problems, "memory+swap limit must not be negative")
+		}
+		if res.MemoryWithSwapMB > 0 {
+			if res.MemoryMB == 0 {
+				problems = append(problems, "memory+swap limit requires a memory limit")
+			} else if res.MemoryWithSwapMB < res.MemoryMB {
+				problems = append(problems, "memory+swap limit must not be less than the memory limit")
+			}
+		}
+	}
+
+	return problems
+}
+
+// validateEnv checks the creation request against the current state of Podman,
+// including the image, the names and the ports in use.
+func validateEnv(ctx context.Context, c *createReq) ([]string, error) {
+	problems := []string{}
+
+	if c.Image != "" {
+		_, err := adapter.ImageGet(ctx, c.Image, nil)
+		if err != nil {
+			if !utils.IsErr404(err) {
+				return nil, fmt.Errorf("cannot inspect image %s: %v", c.Image, err)
+			}
+			problems = append(problems, fmt.Sprintf("image %s does not exist", c.Image))
+		}
+	}
+
+	if res := c.Resources; res != nil && res.CpuCores > 0 {
+		info, err := adapter.SystemInfo(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get system info: %v", err)
+		}
+		if info.Host != nil && info.Host.CPUs > 0 && res.CpuCores > float64(info.Host.CPUs) {
+			problems = append(problems, fmt.Sprintf("cpu cores %g exceed the %d CPUs available", res.CpuCores, info.Host.CPUs))
+		}
+	}
+
+	list, err := adapter.ContainerList(ctx, (&containers.ListOptions{}).WithAll(true))
+	if err != nil {
+		return nil, fmt.Errorf("cannot list containers: %v", err)
+	}
+	for _, ctn := range list {
+		for _, name := range ctn.Names {
+			if c.Name != "" && name == c.Name {
+				problems = append(problems, fmt.Sprintf("name %s is already in use by container %s", c.Name, ctn.ID[:12]))
+			}
+		}
+
+		if ctn.State != "running" {
+			continue
+		}
+		for _, p := range c.Ports {
+			for _, h := range p.Host {
+				if h.Port == 0 {
+					continue
+				}
+				for _, used := range ctn.Ports {
+					if !protocolOverlaps(used.Protocol, p.Protocol) || !addrOverlaps(used.HostIP, h.Addr) {
+						continue
+					}
+					portRange := used.Range
+					if portRange == 0 {
+						portRange = 1
+					}
+					if h.Port >= used.HostPort && uint32(h.Port) < uint32(used.HostPort)+uint32(portRange) {
+						problems = append(problems, fmt.Sprintf("host port %d/%s is already used by container %s", h.Port, p.Protocol, strings.Join(ctn.Names, ",")))
+					}
+				}
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+func isCleanAbsPath(p string) bool {
+	return path.IsAbs(p) && path.Clean(p) == p
+}
+
+// addrOverlaps reports whether the two host addresses may conflict. An empty address means all interfaces.
+func addrOverlaps(a, b string) bool {
+	isAll := func(addr string) bool {
+		return addr == "" || addr == "0.0.0.0" || addr == "::"
+	}
+	return isAll(a) || isAll(b) || a == b
+}
+
+// protocolOverlaps reports whether the protocols, which may be comma separated, have one in common.
+func protocolOverlaps(used, protocol string) bool {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	for _, p := range strings.Split(used, ",") {
+		if p == protocol || (p == "" && protocol == "tcp") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package image
+
+import (
+	"containerup/adapter"
+	"containerup/conn"
+	"containerup/transfer"
+	"containerup/utils"
+	"fmt"
+	"github.com/containers/podman/v4/pkg/bindings/images"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	unsafeFilenameRegex = regexp.MustCompile(`[^\w.-]+`)
+)
+
+func Import(w http.ResponseWriter, req *http.Request) {
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+	defer req.Body.Close()
+
+	importOpts := &images.ImportOptions{}
+	if ref := query.Get("reference"); ref != "" {
+		importOpts.WithReference(ref)
+	}
+	if msg := query.Get("message"); msg != "" {
+		importOpts.WithMessage(msg)
+	}
+	if changes := query["change"]; len(changes) > 0 {
+		importOpts.WithChanges(changes)
+	}
+
+	t := transfer.Start(req, "image-import", query.Get("reference"), req.ContentLength)
+	w.Header().Set("X-Transfer-Id", t.Id())
+
+	report, err := adapter.ImageImport(pmConn, t.Reader(req.Body), importOpts)
+	t.Finish(err)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot import image: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, report)
+}
+
+func Save(w http.ResponseWriter, req *http.Request) {
+	pmConn := conn.GetConn(req.Context())
+	query := req.URL.Query()
+
+	names := query["names"]
+	if len(names) == 0 {
+		http.Error(w, "images are not specified", http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "docker-archive"
+	}
+	switch format {
+	case "docker-archive":
+	case "oci-archive":
+		if len(names) > 1 {
+			http.Error(w, "multiple images can only be saved as docker-archive", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "unrecognized format", http.StatusBadRequest)
+		return
+	}
+
+	exportOpts := (&images.ExportOptions{}).WithFormat(format)
+	if query.Get("compress") == "1" {
+		exportOpts.WithCompress(true)
+	}
+
+	filename := unsafeFilenameRegex.ReplaceAllString(names[0], "_")
+	if len(names) > 1 {
+		filename = "images"
+	}
+	filename = strings.Trim(filename, "_") + ".tar"
+
+	t := transfer.Start(req, "image-save", strings.Join(names, ","), -1)
+	w.Header().Set("X-Transfer-Id", t.Id())
+	dw := transfer.NewDownloadWriter(w, "application/x-tar", filename)
+
+	err := adapter.ImageExport(pmConn, names, t.Writer(dw), exportOpts)
+	t.Finish(err)
+	if err != nil {
+		if !dw.Started() {
+			if utils.IsErr404(err) {
+				http.Error(w, "Cannot find such image", http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Cannot save images: %v", err), http.StatusInternalServerError)
+			return
+		}
+		utils.AbortResponse("Save images %v: %v", names, err)
+	}
+}
+
+func Load(w http.ResponseWriter, req *http.Request) {
+	pmConn := conn.GetConn(req.Context())
+	defer req.Body.Close()
+
+	t := transfer.Start(req, "image-load", "", req.ContentLength)
+	w.Header().Set("X-Transfer-Id", t.Id())
+
+	report, err := adapter.ImageLoad(pmConn, t.Reader(req.Body))
+	t.Finish(err)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot load images: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	utils.Return(w, report)
+}
@@ -76,3 +76,19 @@ func WebsocketAuth(conn *websocket.Conn, ctx context.Context) bool {
 
 	return true
 }
+
+// HeaderAuth authenticates the websocket with the Bearer token in the headers of the upgrade request,
+// for the clients unable to send the key as the first message.
+func HeaderAuth(req *http.Request) bool {
+	key := getKeyFromHeaders(req.Header)
+	if !checkKey(key) {
+		return false
+	}
+
+	go func() {
+		<-req.Context().Done()
+		unuseKey(key)
+	}()
+
+	return true
+}
@@ -51,6 +51,11 @@ func InitLogin(u, p string) {
 	}
 }
 
+// Username returns the username to be used on the web.
+func Username() string {
+	return username
+}
+
 type loginReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
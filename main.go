@@ -6,6 +6,7 @@ import (
 	"containerup/container"
 	"containerup/image"
 	"containerup/login"
+	"containerup/recording"
 	"containerup/system"
 	"containerup/update"
 	"containerup/utils"
@@ -38,12 +39,24 @@ var (
 		"When specified, the listening port will serve TLS instead of plaintext.")
 	fTlsKey  = flag.String("tls-key", "", "Path of TLS key")
 	fVersion = flag.Bool("version", false, "Show the version of ContainerUp, then exit.")
+
+	fRecordingDir = flag.String("recording-dir", "", "Directory to store the recordings of exec sessions. "+
+		"When specified, sessions can be recorded by request.")
+	fRecordingAll       = flag.Bool("recording-all", false, "Record every exec session. Requires --recording-dir.")
+	fRecordingRetention = flag.Duration("recording-retention", 30*24*time.Hour, "How long the recordings are kept, 0 to keep forever.")
+
+	fShellProfiles = flag.String("shell-profiles", "", "Path of the file to store the shell profiles of containers. "+
+		"When not specified, the saved profiles are lost on restart.")
+
+	fStatsHistory = flag.Bool("stats-history", true, "Collect the history of stats in the background.")
 )
 
 var (
 	timeout       = 2 * time.Minute
 	wsTimeout     = 60 * time.Minute
 	wsLongTimeout = 8 * time.Hour
+
+	transferTimeout = 60 * time.Minute
 )
 
 func main() {
@@ -70,11 +83,33 @@ func main() {
 
 	login.InitLogin(*fUsername, *fPasswordHash)
 
+	if *fRecordingDir != "" {
+		if err := recording.Init(*fRecordingDir, *fRecordingAll, *fRecordingRetention); err != nil {
+			log.Fatalf("Cannot initialize recording: %v", err)
+		}
+	} else if *fRecordingAll {
+		log.Fatalf("--recording-all requires --recording-dir")
+	}
+
+	if *fShellProfiles != "" {
+		if err := container.InitShellProfiles(*fShellProfiles); err != nil {
+			log.Fatalf("Cannot load shell profiles: %v", err)
+		}
+	}
+
 	chainConn, err := conn.ConnectionChainer(*fPodman)
 	if err != nil {
 		log.Fatalf("Cannot initialize connection to podman: %v", err)
 	}
 
+	if *fStatsHistory {
+		statsConn, err := adapter.NewConnection(context.Background(), *fPodman)
+		if err != nil {
+			log.Fatalf("Cannot initialize connection to podman: %v", err)
+		}
+		system.StartStatsHistory(statsConn)
+	}
+
 	r := mux.NewRouter()
 	r.Use(utils.MiddlewareLogger)
 
@@ -86,18 +121,48 @@ func main() {
 
 	api.HandleFunc("/container", chain(chainConn, timeout, container.List)).Methods(http.MethodGet)
 	api.HandleFunc("/container", chain(chainConn, timeout, container.Create)).Methods(http.MethodPost)
+	api.HandleFunc("/container/logs", chainWs(chainConn, wsTimeout, container.AggregatedLogs)).Methods(http.MethodGet)
+	api.HandleFunc("/container/restore", chain(chainConn, transferTimeout, container.RestoreImport)).Methods(http.MethodPost)
+	api.HandleFunc("/container/from-command", chain(chainConn, timeout, container.CreateFromCommand)).Methods(http.MethodPost)
 	api.HandleFunc("/container/{name}/inspect", chain(chainConn, timeout, container.Inspect)).Methods(http.MethodGet)
 	api.HandleFunc("/container/{name}/logs", chainWs(chainConn, wsTimeout, container.Logs)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/logs/download", chain(chainConn, transferTimeout, container.LogsDownload)).Methods(http.MethodGet)
 	api.HandleFunc("/container/{name}/exec", chainWs(chainConn, wsTimeout, container.Exec)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/attach", chainWs(chainConn, wsTimeout, container.Attach)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/exec", chain(chainConn, transferTimeout, container.RunExec)).Methods(http.MethodPost)
+	api.HandleFunc("/container/{name}/shell", chain(chainConn, timeout, container.ShellProfiles)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/shell", chain(chainConn, timeout, container.SaveShellProfiles)).Methods(http.MethodPut)
+	api.HandleFunc("/container/{name}/top", chain(chainConn, timeout, container.Top)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/export", chain(chainConn, transferTimeout, container.Export)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/checkpoint", chain(chainConn, transferTimeout, container.CheckpointExport)).Methods(http.MethodPost)
+	api.HandleFunc("/container/{name}/changes", chain(chainConn, timeout, container.Changes)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/files", chain(chainConn, timeout, container.ListFiles)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/files/download", chain(chainConn, transferTimeout, container.DownloadFiles)).Methods(http.MethodGet)
+	api.HandleFunc("/container/{name}/files/upload", chain(chainConn, transferTimeout, container.UploadFiles)).Methods(http.MethodPost)
+	api.HandleFunc("/container/{name}/healthcheck", chain(chainConn, timeout, container.HealthCheck)).Methods(http.MethodPost)
+	api.HandleFunc("/container/{name}/reimage", chainWs(chainConn, wsTimeout, container.Reimage)).Methods(http.MethodGet)
 	api.HandleFunc("/container/{name}", chain(chainConn, timeout, container.Action)).Methods(http.MethodPost)
 	api.HandleFunc("/container/{name}", chain(chainConn, timeout, container.Patch)).Methods(http.MethodPatch)
 
 	api.HandleFunc("/image", chain(chainConn, timeout, image.List)).Methods(http.MethodGet)
+	api.HandleFunc("/image/import", chain(chainConn, transferTimeout, image.Import)).Methods(http.MethodPost)
+	api.HandleFunc("/image/load", chain(chainConn, transferTimeout, image.Load)).Methods(http.MethodPost)
+	api.HandleFunc("/image/save", chain(chainConn, transferTimeout, image.Save)).Methods(http.MethodGet)
 	api.HandleFunc("/image/pull", chainWs(chainConn, wsTimeout, image.Pull)).Methods(http.MethodGet)
 	api.HandleFunc("/image/{name}/inspect", chain(chainConn, timeout, image.Inspect)).Methods(http.MethodGet)
 	api.HandleFunc("/image/{name}", chain(chainConn, timeout, image.Action)).Methods(http.MethodPost)
 
+	api.HandleFunc("/terminal", chain(chainConn, timeout, container.ListTerminals)).Methods(http.MethodGet)
+	api.HandleFunc("/terminal/{id}", chainWs(chainConn, wsTimeout, container.AttachTerminal)).Methods(http.MethodGet)
+	api.HandleFunc("/terminal/{id}", chain(chainConn, timeout, container.KillTerminal)).Methods(http.MethodDelete)
+
+	api.HandleFunc("/recording", chain(chainConn, timeout, recording.List)).Methods(http.MethodGet)
+	api.HandleFunc("/recording/{id}/download", chain(chainConn, transferTimeout, recording.Download)).Methods(http.MethodGet)
+	api.HandleFunc("/recording/{id}/replay", chainWs(chainConn, wsLongTimeout, recording.Replay)).Methods(http.MethodGet)
+
 	api.HandleFunc("/system/info", chain(chainConn, timeout, system.Info)).Methods(http.MethodGet)
+	api.HandleFunc("/stats/history", chain(chainConn, timeout, system.StatsHistory)).Methods(http.MethodGet)
+	api.HandleFunc("/system/checkpoint", chain(chainConn, timeout, container.CheckpointSupport)).Methods(http.MethodGet)
 	api.HandleFunc("/system/update", chain(chainConn, timeout, system.UpdateCheck)).Methods(http.MethodGet)
 	api.HandleFunc("/system/update", chain(chainConn, timeout, system.UpdateAction)).Methods(http.MethodPost)
 
@@ -0,0 +1,272 @@
+package recording
+
+import (
+	"bufio"
+	"containerup/login"
+	"containerup/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the end of the file read to find the duration
+	tailSize = 64 * 1024
+	maxSpeed = 64
+)
+
+var (
+	upgrader = websocket.Upgrader{}
+)
+
+type recordingResp struct {
+	Id        string  `json:"id"`
+	User      string  `json:"user"`
+	Container string  `json:"container"`
+	Remote    string  `json:"remote"`
+	Command   string  `json:"command"`
+	Start     int64   `json:"start"`
+	Duration  float64 `json:"duration"`
+	Size      int64   `json:"size"`
+}
+
+func List(w http.ResponseWriter, req *http.Request) {
+	ret := []*recordingResp{}
+	if dir == "" {
+		utils.Return(w, ret)
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	container := req.URL.Query().Get("container")
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ext) {
+			continue
+		}
+		r, err := describe(strings.TrimSuffix(e.Name(), ext))
+		if err != nil {
+			log.Printf("Read recording %s: %v", e.Name(), err)
+			continue
+		}
+		if container != "" && r.Container != container {
+			continue
+		}
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Start > ret[j].Start
+	})
+
+	utils.Return(w, ret)
+}
+
+func Download(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	f, err := open(id)
+	if err != nil {
+		replyErr(w, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": id + ext}))
+	_, _ = io.Copy(w, f)
+}
+
+// Replay sends the output of the recording with the original timing, in the frames of exec.
+// The replay is faster with speed=N, and idle time longer than maxIdle=N seconds is skipped.
+func Replay(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	query := req.URL.Query()
+
+	speed := 1.0
+	if s := query.Get("speed"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 || v > maxSpeed {
+			http.Error(w, fmt.Sprintf("speed should be between 0 and %d", maxSpeed), http.StatusBadRequest)
+			return
+		}
+		speed = v
+	}
+	var maxIdle float64
+	if m := query.Get("maxIdle"); m != "" {
+		v, err := strconv.ParseFloat(m, 64)
+		if err != nil || v <= 0 {
+			http.Error(w, "invalid maxIdle", http.StatusBadRequest)
+			return
+		}
+		maxIdle = v
+	}
+
+	f, err := open(id)
+	if err != nil {
+		replyErr(w, err)
+		return
+	}
+	defer f.Close()
+
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// err replied in upgrader.Upgrade
+		return
+	}
+	defer ws.Close()
+
+	if !login.WebsocketAuth(ws, req.Context()) {
+		return
+	}
+
+	ctx := req.Context()
+	closed := make(chan struct{})
+	go func() {
+		// empty read, until the client closes
+		defer close(closed)
+		var err error
+		for err == nil {
+			_, _, err = ws.ReadMessage()
+		}
+	}()
+
+	text := ""
+	err = replay(f, speed, maxIdle, func(elapsed float64, kind, data string) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-closed:
+			return io.EOF
+		case <-time.After(time.Duration(elapsed * float64(time.Second))):
+		}
+
+		switch kind {
+		case "o":
+			return ws.WriteMessage(websocket.BinaryMessage, append([]byte{'1'}, data...))
+		case "r":
+			var width, height int
+			if _, err := fmt.Sscanf(data, "%dx%d", &width, &height); err != nil {
+				return nil
+			}
+			return ws.WriteMessage(websocket.BinaryMessage, []byte{'r', byte(width >> 8), byte(width), byte(height >> 8), byte(height)})
+		case "m":
+			text = data
+		}
+		return nil
+	})
+
+	wsCode := websocket.CloseNormalClosure
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		wsCode = websocket.CloseInternalServerErr
+		text = err.Error()
+	}
+	_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(wsCode, text))
+}
+
+// replay reads the events, and calls fn with the time to wait since the last event.
+func replay(r io.Reader, speed, maxIdle float64, fn func(wait float64, kind, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	// header
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+
+	last := 0.0
+	for scanner.Scan() {
+		var ev []any
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil || len(ev) != 3 {
+			continue
+		}
+		t, ok1 := ev[0].(float64)
+		kind, ok2 := ev[1].(string)
+		data, ok3 := ev[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+
+		wait := t - last
+		last = t
+		if maxIdle > 0 && wait > maxIdle {
+			wait = maxIdle
+		}
+		if err := fn(wait/speed, kind, data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// describe reads the header and the duration of the recording.
+func describe(id string) (*recordingResp, error) {
+	f, err := open(id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h, err := readHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recordingResp{
+		Id:        id,
+		User:      h.Tags.User,
+		Container: h.Tags.Container,
+		Remote:    h.Tags.Remote,
+		Command:   h.Command,
+		Start:     h.Timestamp,
+		Size:      info.Size(),
+	}
+
+	// the time of the last event
+	offset := info.Size() - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var ev []any
+		if json.Unmarshal([]byte(lines[i]), &ev) == nil && len(ev) == 3 {
+			if t, ok := ev[0].(float64); ok {
+				r.Duration = t
+				break
+			}
+		}
+	}
+	return r, nil
+}
+
+func replyErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,264 @@
+package recording
+
+import (
+	"bufio"
+	"containerup/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ext = ".cast"
+
+	defaultWidth  = 80
+	defaultHeight = 24
+
+	pruneInterval = time.Hour
+)
+
+var (
+	ErrNotFound = errors.New("no such recording")
+
+	dir       string
+	recordAll bool
+	retention time.Duration
+)
+
+// Header is the header line of asciicast v2, with the tags of the exec session.
+type Header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Command   string `json:"command,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Tags      Tags   `json:"containerup"`
+}
+
+type Tags struct {
+	User      string `json:"user"`
+	Container string `json:"container"`
+	Remote    string `json:"remote"`
+}
+
+// Init enables recording with the directory to store the recordings.
+// If all is set, every exec session is recorded, otherwise only those requested with `record=1`.
+// Recordings older than ret are removed, or kept forever if ret is 0.
+func Init(d string, all bool, ret time.Duration) error {
+	if err := os.MkdirAll(d, 0700); err != nil {
+		return err
+	}
+	dir = d
+	recordAll = all
+	retention = ret
+
+	if retention > 0 {
+		go func() {
+			for {
+				prune()
+				time.Sleep(pruneInterval)
+			}
+		}()
+	}
+	return nil
+}
+
+// Enabled reports whether the exec session of the request should be recorded.
+func Enabled(req *http.Request) bool {
+	if dir == "" {
+		return false
+	}
+	return recordAll || req.URL.Query().Get("record") == "1"
+}
+
+// Recorder writes an exec session into an asciicast v2 file. Methods of a nil Recorder do nothing.
+type Recorder struct {
+	mutex sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+	err   error
+
+	// incomplete UTF-8 sequences at the end of the last chunks
+	pendingIn  []byte
+	pendingOut []byte
+}
+
+// Start creates a recording.
+func Start(tags Tags, command []string) (*Recorder, error) {
+	now := time.Now()
+	id := fmt.Sprintf("%s-%s", now.UTC().Format("20060102T150405Z"), utils.RandString(8))
+	f, err := os.OpenFile(filepath.Join(dir, id+ext), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := strings.Join(command, " ")
+	h := &Header{
+		Version:   2,
+		Width:     defaultWidth,
+		Height:    defaultHeight,
+		Timestamp: now.Unix(),
+		Command:   cmd,
+		Title:     fmt.Sprintf("%s@%s: %s", tags.User, tags.Container, cmd),
+		Tags:      tags,
+	}
+	r := &Recorder{
+		f:     f,
+		w:     bufio.NewWriter(f),
+		start: now,
+	}
+	if err := r.writeLine(h); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) Input(b []byte) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pendingIn = r.event("i", r.pendingIn, b)
+}
+
+// Output records stdout and stderr, which are not distinguished in asciicast.
+func (r *Recorder) Output(b []byte) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pendingOut = r.event("o", r.pendingOut, b)
+}
+
+func (r *Recorder) Resize(width, height int) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.writeEvent("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Close ends the recording with the exit code as the last output.
+func (r *Recorder) Close(exitCode int) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.writeEvent("m", fmt.Sprintf("ExitCode %d", exitCode))
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		log.Printf("Recording %s: %v", r.f.Name(), r.err)
+	}
+}
+
+// event writes the data with the pending bytes, and returns the bytes of an incomplete UTF-8 sequence at the end.
+func (r *Recorder) event(kind string, pending, b []byte) []byte {
+	data := append(pending, b...)
+	cut := len(data)
+	// a UTF-8 sequence has at most 4 bytes
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	if cut > 0 {
+		r.writeEvent(kind, string(data[:cut]))
+	}
+	return append([]byte(nil), data[cut:]...)
+}
+
+func (r *Recorder) writeEvent(kind, data string) {
+	elapsed := time.Since(r.start).Seconds()
+	_ = r.writeLine([]any{elapsed, kind, data})
+}
+
+func (r *Recorder) writeLine(v any) error {
+	if r.err != nil {
+		return r.err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return err
+	}
+	b = append(b, '\n')
+	_, r.err = r.w.Write(b)
+	if r.err == nil {
+		// flushed for every line, so that the recording survives a crash
+		r.err = r.w.Flush()
+	}
+	return r.err
+}
+
+// open opens the recording by ID.
+func open(id string) (*os.File, error) {
+	if dir == "" || id == "" || strings.ContainsAny(id, "/\\.") {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(dir, id+ext))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func readHeader(r io.Reader) (*Header, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, err
+	}
+	var h Header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func prune() {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Prune recordings: %v", err)
+		return
+	}
+	deadline := time.Now().Add(-retention)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ext) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			log.Printf("Prune recording %s: %v", e.Name(), err)
+		}
+	}
+}
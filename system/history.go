@@ -0,0 +1,351 @@
+package system
+
+import (
+	"containerup/container"
+	"containerup/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/containers/podman/v4/libpod/define"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	historyInterval = 5
+	// the collector reconnects after the delay if the stats stream of Podman ends
+	historyRetryDelay   = 30 * time.Second
+	defaultHistoryRange = time.Hour
+)
+
+var (
+	// from the finest to the coarsest, each averaging the samples in its step
+	historyTiers = []struct {
+		step      time.Duration
+		retention time.Duration
+	}{
+		{5 * time.Second, time.Hour},
+		{time.Minute, 24 * time.Hour},
+		{10 * time.Minute, 30 * 24 * time.Hour},
+	}
+
+	// by the ID of the container, the total of all containers with the empty ID
+	historySeries = map[string]*historySeriesT{}
+	historyMutex  sync.Mutex
+
+	errHistoryStreamEnded = errors.New("the stats stream has ended")
+)
+
+type historyPoint struct {
+	Time       int64   `json:"time"` // the start of the step, in unix seconds
+	Cpu        float64 `json:"cpu"`  // in cores
+	Memory     uint64  `json:"memory"`
+	NetworkIn  uint64  `json:"networkIn"` // in bytes per second, as well as below
+	NetworkOut uint64  `json:"networkOut"`
+	BlockIn    uint64  `json:"blockIn"`
+	BlockOut   uint64  `json:"blockOut"`
+}
+
+type historyResp struct {
+	Container string          `json:"container"`
+	Name      string          `json:"name"`
+	Step      int64           `json:"step"` // in seconds
+	Points    []*historyPoint `json:"points"`
+}
+
+type historySeriesT struct {
+	id    string
+	name  string
+	last  time.Time
+	tiers []*historyTier
+}
+
+// historyTier is a ring buffer of the points of a step.
+type historyTier struct {
+	step   int64
+	points []historyPoint
+	next   int // where the next point is written once the buffer is full
+
+	// the sum of the samples of the step in progress
+	sum   historyPoint
+	count int
+}
+
+// StartStatsHistory collects the stats of the containers in the background, until ctx is done.
+func StartStatsHistory(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			err := collectStatsHistory(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Stats history collector: %v", err)
+			time.Sleep(historyRetryDelay)
+		}
+	}()
+}
+
+func collectStatsHistory(ctx context.Context) error {
+	ch, err := container.TotalStatsStream(ctx, historyInterval)
+	if err != nil {
+		return err
+	}
+
+	prev := map[string]define.ContainerStats{}
+	var prevTime time.Time
+	first := true
+	for stats := range ch {
+		now := time.Now()
+		// the rates are of the time elapsed actually, as samples may be delayed or missed
+		elapsed := now.Sub(prevTime).Seconds()
+		rate := func(cur, prev uint64) uint64 {
+			return uint64(float64(counterDelta(cur, prev)) / elapsed)
+		}
+		current := map[string]define.ContainerStats{}
+		points := map[string]*historyPoint{}
+		names := map[string]string{}
+
+		// the total is summed from the deltas of the containers, which take the restarts into account
+		total := &historyPoint{Time: now.Unix()}
+		for _, rpt := range stats.Containers {
+			current[rpt.ContainerID] = rpt
+			names[rpt.ContainerID] = rpt.Name
+			total.Memory += rpt.MemUsage
+			p, ok := prev[rpt.ContainerID]
+			if !ok {
+				// the first sample of the container has no deltas
+				continue
+			}
+			point := &historyPoint{
+				Time:       now.Unix(),
+				Cpu:        float64(counterDelta(rpt.CPUNano, p.CPUNano)) / float64(time.Second) / elapsed,
+				Memory:     rpt.MemUsage,
+				NetworkIn:  rate(rpt.NetInput, p.NetInput),
+				NetworkOut: rate(rpt.NetOutput, p.NetOutput),
+				BlockIn:    rate(rpt.BlockInput, p.BlockInput),
+				BlockOut:   rate(rpt.BlockOutput, p.BlockOutput),
+			}
+			points[rpt.ContainerID] = point
+			total.Cpu += point.Cpu
+			total.NetworkIn += point.NetworkIn
+			total.NetworkOut += point.NetworkOut
+			total.BlockIn += point.BlockIn
+			total.BlockOut += point.BlockOut
+		}
+		prev, prevTime = current, now
+
+		if first {
+			first = false
+			continue
+		}
+
+		points[""] = total
+		recordHistory(now, points, names)
+	}
+
+	return errHistoryStreamEnded
+}
+
+// counterDelta returns the increase of a counter, which is reset if the container is restarted.
+func counterDelta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+func recordHistory(now time.Time, points map[string]*historyPoint, names map[string]string) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	for id, p := range points {
+		s, ok := historySeries[id]
+		if !ok {
+			s = newHistorySeries(id)
+			historySeries[id] = s
+		}
+		if name := names[id]; name != "" {
+			s.name = name
+		}
+		s.last = now
+		for _, t := range s.tiers {
+			t.add(p)
+		}
+	}
+
+	// forget the containers removed for longer than the retention
+	retention := historyTiers[len(historyTiers)-1].retention
+	for id, s := range historySeries {
+		if now.Sub(s.last) > retention {
+			delete(historySeries, id)
+		}
+	}
+}
+
+func newHistorySeries(id string) *historySeriesT {
+	s := &historySeriesT{id: id}
+	for _, t := range historyTiers {
+		s.tiers = append(s.tiers, &historyTier{
+			step:   int64(t.step / time.Second),
+			points: make([]historyPoint, 0, int(t.retention/t.step)),
+		})
+	}
+	return s
+}
+
+func (t *historyTier) add(p *historyPoint) {
+	start := p.Time - p.Time%t.step
+	if t.count > 0 && start != t.sum.Time {
+		t.flush()
+	}
+	if t.count == 0 {
+		t.sum = historyPoint{Time: start}
+	}
+	t.sum.Cpu += p.Cpu
+	t.sum.Memory += p.Memory
+	t.sum.NetworkIn += p.NetworkIn
+	t.sum.NetworkOut += p.NetworkOut
+	t.sum.BlockIn += p.BlockIn
+	t.sum.BlockOut += p.BlockOut
+	t.count++
+}
+
+// average returns the average of the step in progress.
+func (t *historyTier) average() historyPoint {
+	n := uint64(t.count)
+	return historyPoint{
+		Time:       t.sum.Time,
+		Cpu:        t.sum.Cpu / float64(n),
+		Memory:     t.sum.Memory / n,
+		NetworkIn:  t.sum.NetworkIn / n,
+		NetworkOut: t.sum.NetworkOut / n,
+		BlockIn:    t.sum.BlockIn / n,
+		BlockOut:   t.sum.BlockOut / n,
+	}
+}
+
+func (t *historyTier) flush() {
+	p := t.average()
+	t.count = 0
+	if len(t.points) < cap(t.points) {
+		t.points = append(t.points, p)
+		return
+	}
+	t.points[t.next] = p
+	t.next = (t.next + 1) % len(t.points)
+}
+
+// query returns the points between since and until, including the step in progress.
+func (t *historyTier) query(since, until int64) []*historyPoint {
+	ret := make([]*historyPoint, 0)
+	add := func(p historyPoint) {
+		if p.Time+t.step > since && p.Time <= until {
+			ret = append(ret, &p)
+		}
+	}
+	for _, p := range t.points[t.next:] {
+		add(p)
+	}
+	for _, p := range t.points[:t.next] {
+		add(p)
+	}
+	if t.count > 0 {
+		add(t.average())
+	}
+	return ret
+}
+
+// StatsHistory queries the history of the stats, of all containers or the container=NAME only.
+// The range is since=T and until=T in unix seconds, the last hour by default.
+// The finest step retained for the range is used, or at least step=N seconds if specified.
+func StatsHistory(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	ctn := query.Get("container")
+
+	now := time.Now().Unix()
+	since := now - int64(defaultHistoryRange/time.Second)
+	until := now
+	var step int64
+	for _, q := range []struct {
+		key string
+		val *int64
+	}{{"since", &since}, {"until", &until}, {"step", &step}} {
+		if v := query.Get(q.key); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				http.Error(w, fmt.Sprintf("invalid %s: %s", q.key, v), http.StatusBadRequest)
+				return
+			}
+			*q.val = n
+		}
+	}
+	if since > until {
+		http.Error(w, "since should not be later than until", http.StatusBadRequest)
+		return
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	s, err := historySeriesOf(ctn)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	tier := historyTierIndex(now-since, step)
+	t := s.tiers[tier]
+	utils.Return(w, &historyResp{
+		Container: s.id,
+		Name:      s.name,
+		Step:      t.step,
+		Points:    t.query(since, until),
+	})
+}
+
+// historySeriesOf finds the series by the name, ID or the prefix of ID of the container, or the total if empty.
+// historyMutex should be held.
+func historySeriesOf(ctn string) (*historySeriesT, error) {
+	var found *historySeriesT
+	if ctn == "" {
+		found = historySeries[""]
+	} else if s, ok := historySeries[ctn]; ok {
+		found = s
+	} else {
+		for id, s := range historySeries {
+			if id != "" && s.name == ctn {
+				return s, nil
+			}
+		}
+		for id, s := range historySeries {
+			if id == "" || !strings.HasPrefix(id, ctn) {
+				continue
+			}
+			if found != nil {
+				return nil, fmt.Errorf("%s matches multiple containers", ctn)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		if ctn == "" {
+			return nil, errors.New("no stats have been collected yet")
+		}
+		return nil, fmt.Errorf("Cannot find the stats history of container %s", ctn)
+	}
+	return found, nil
+}
+
+// historyTierIndex returns the finest tier retaining the range, with the step no finer than the step.
+func historyTierIndex(rangeSec, step int64) int {
+	for i, t := range historyTiers {
+		if int64(t.retention/time.Second) >= rangeSec && int64(t.step/time.Second) >= step {
+			return i
+		}
+	}
+	return len(historyTiers) - 1
+}
@@ -0,0 +1,109 @@
+package transfer
+
+import (
+	"containerup/wsrouter/wstypes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	progressInterval = time.Second
+	// the client may subscribe before the transfer starts
+	waitForStart = 10 * time.Second
+)
+
+var (
+	subTransferMap   = map[uint]func(){}
+	subTransferMutex sync.Mutex
+)
+
+func SubscribeToTransfer(ctx context.Context, msg *wstypes.WsReqMessage, writer chan<- *wstypes.WsRespMessage) {
+	var id string
+	err := json.Unmarshal(msg.Data, &id)
+	if err != nil {
+		writer <- &wstypes.WsRespMessage{
+			Index: msg.Index,
+			Error: true,
+			Data:  err.Error(),
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	subTransferMutex.Lock()
+	subTransferMap[msg.Index] = cancel
+	subTransferMutex.Unlock()
+	defer func() {
+		subTransferMutex.Lock()
+		defer subTransferMutex.Unlock()
+		delete(subTransferMap, msg.Index)
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	waitUntil := time.Now().Add(waitForStart)
+	var last *Progress
+	for {
+		if t := get(id); t != nil {
+			p := t.Progress()
+			if last == nil || *last != p {
+				last = &p
+				writer <- &wstypes.WsRespMessage{
+					Index: msg.Index,
+					Data:  p,
+				}
+			}
+			if p.Phase == PhaseDone || p.Phase == PhaseFailed {
+				break
+			}
+		} else if last != nil || time.Now().After(waitUntil) {
+			writer <- &wstypes.WsRespMessage{
+				Index: msg.Index,
+				Error: true,
+				Data:  "no such transfer",
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	writer <- &wstypes.WsRespMessage{
+		Index: msg.Index,
+		Error: false,
+		Data:  nil,
+	}
+}
+
+func UnsubscribeToTransfer(ctx context.Context, msg *wstypes.WsReqMessage, writer chan<- *wstypes.WsRespMessage) {
+	var unsubId uint
+	err := json.Unmarshal(msg.Data, &unsubId)
+	if err != nil {
+		writer <- &wstypes.WsRespMessage{
+			Index: msg.Index,
+			Data:  false,
+		}
+		return
+	}
+
+	subTransferMutex.Lock()
+	if c, ok := subTransferMap[unsubId]; ok {
+		c()
+		delete(subTransferMap, unsubId)
+	}
+	subTransferMutex.Unlock()
+
+	writer <- &wstypes.WsRespMessage{
+		Index: msg.Index,
+		Data:  true,
+	}
+}
@@ -0,0 +1,180 @@
+package transfer
+
+import (
+	"containerup/utils"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PhaseTransferring = "transferring"
+	PhaseProcessing   = "processing"
+	PhaseDone         = "done"
+	PhaseFailed       = "failed"
+
+	// finished transfers are kept for a while, so that late subscribers get the result
+	keepFinished = time.Minute
+)
+
+var (
+	transfers      = map[string]*Transfer{}
+	transfersMutex sync.Mutex
+)
+
+type Progress struct {
+	Id    string `json:"id"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	Total int64  `json:"total"` // -1 if unknown
+	Phase string `json:"phase"`
+	Error string `json:"error"`
+}
+
+// Transfer tracks the progress of a large upload or download.
+type Transfer struct {
+	bytes atomic.Int64
+	mutex sync.Mutex
+	p     Progress
+}
+
+// Start registers a transfer. The ID is taken from query `transferId` if specified by the client,
+// so that the client can subscribe to the progress before the transfer starts.
+func Start(req *http.Request, kind, name string, total int64) *Transfer {
+	id := req.URL.Query().Get("transferId")
+	if id == "" {
+		id = utils.RandString(16)
+	}
+
+	t := &Transfer{
+		p: Progress{
+			Id:    id,
+			Kind:  kind,
+			Name:  name,
+			Total: total,
+			Phase: PhaseTransferring,
+		},
+	}
+
+	transfersMutex.Lock()
+	transfers[id] = t
+	transfersMutex.Unlock()
+
+	return t
+}
+
+func (t *Transfer) Id() string {
+	return t.p.Id
+}
+
+func (t *Transfer) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, t: t}
+}
+
+func (t *Transfer) Writer(w io.Writer) io.Writer {
+	return &countingWriter{w: w, t: t}
+}
+
+func (t *Transfer) SetPhase(phase string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.p.Phase = phase
+}
+
+// Finish marks the transfer done or failed, and removes it later.
+func (t *Transfer) Finish(err error) {
+	t.mutex.Lock()
+	t.p.Phase = PhaseDone
+	if err != nil {
+		t.p.Phase = PhaseFailed
+		t.p.Error = err.Error()
+	}
+	t.mutex.Unlock()
+
+	time.AfterFunc(keepFinished, func() {
+		transfersMutex.Lock()
+		defer transfersMutex.Unlock()
+		if transfers[t.p.Id] == t {
+			delete(transfers, t.p.Id)
+		}
+	})
+}
+
+func (t *Transfer) Progress() Progress {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	p := t.p
+	p.Bytes = t.bytes.Load()
+	return p
+}
+
+func get(id string) *Transfer {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+	return transfers[id]
+}
+
+type countingReader struct {
+	r io.Reader
+	t *Transfer
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.t.bytes.Add(int64(n))
+	if err == io.EOF {
+		// the upload is received, Podman is processing it then
+		c.t.mutex.Lock()
+		if c.t.p.Phase == PhaseTransferring {
+			c.t.p.Phase = PhaseProcessing
+		}
+		c.t.mutex.Unlock()
+	}
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	t *Transfer
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.t.bytes.Add(int64(n))
+	return n, err
+}
+
+// DownloadWriter sets the headers of the attachment on the first write,
+// so that errors before any data can still be replied with http.Error.
+type DownloadWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func NewDownloadWriter(w http.ResponseWriter, contentType, filename string) *DownloadWriter {
+	return &DownloadWriter{
+		w:           w,
+		contentType: contentType,
+		filename:    filename,
+	}
+}
+
+func (d *DownloadWriter) Write(b []byte) (int, error) {
+	if !d.started {
+		d.started = true
+		d.w.Header().Set("Content-Type", d.contentType)
+		d.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": d.filename}))
+	}
+	return d.w.Write(b)
+}
+
+// Started reports whether the response has been started.
+func (d *DownloadWriter) Started() bool {
+	return d.started
+}
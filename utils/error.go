@@ -12,6 +12,13 @@ func IsErr404(err error) bool {
 	return false
 }
 
+func IsErr409(err error) bool {
+	if ne, ok := err.(*errorhandling.ErrorModel); ok {
+		return ne.ResponseCode == http.StatusConflict
+	}
+	return false
+}
+
 func IsWsCloseMsgTooLong(err error) bool {
 	return err.Error() == "websocket: invalid control frame"
 }
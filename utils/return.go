@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 )
 
@@ -9,3 +10,10 @@ func Return(w http.ResponseWriter, ret any) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(ret)
 }
+
+// AbortResponse logs the error, and aborts the response which has been started,
+// so that the client does not take it as complete.
+func AbortResponse(format string, args ...any) {
+	log.Printf(format, args...)
+	panic(http.ErrAbortHandler)
+}
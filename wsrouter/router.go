@@ -6,6 +6,7 @@ import (
 	"containerup/image"
 	"containerup/login"
 	"containerup/system"
+	"containerup/transfer"
 	"containerup/wsrouter/wstypes"
 	"context"
 	"github.com/gorilla/websocket"
@@ -121,6 +122,14 @@ func router(ctx context.Context, msg *wstypes.WsReqMessage, writer chan<- *wstyp
 		container.SubscribeToContainerStats(ctx, msg, writer)
 	case "unsubscribeToContainerStats":
 		container.UnsubscribeToContainerStats(ctx, msg, writer)
+	case "subscribeToContainerTop":
+		container.SubscribeToContainerTop(ctx, msg, writer)
+	case "unsubscribeToContainerTop":
+		container.UnsubscribeToContainerTop(ctx, msg, writer)
+	case "subscribeToTransfer":
+		transfer.SubscribeToTransfer(ctx, msg, writer)
+	case "unsubscribeToTransfer":
+		transfer.UnsubscribeToTransfer(ctx, msg, writer)
 	case "subscribeToSystemStats":
 		system.SubscribeToSystemStats(ctx, msg, writer)
 	case "unsubscribeToSystemStats":